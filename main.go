@@ -10,6 +10,7 @@ import (
 	"harmonify/src/api"
 	"harmonify/src/auth"
 	"harmonify/src/handlers"
+	"harmonify/src/playlist"
 )
 
 func init() {
@@ -27,6 +28,8 @@ func init() {
         "urlencodeTitle":  api.UrlencodeTitle,
         "durationMinutes": api.DurationMinutes,
         "durationSeconds": api.DurationSeconds,
+        "formatDuration":  api.FormatDurationMs,
+        "languageName":    api.LanguageName,
     }
 
     var err error
@@ -74,6 +77,46 @@ func init() {
     if err != nil {
         log.Fatalf("Failed to parse register template: %v", err)
     }
+
+    handlers.SharedTemplate, err = template.New("shared.html").Funcs(funcMap).ParseFiles("templates/shared.html")
+    if err != nil {
+        log.Fatalf("Failed to parse shared template: %v", err)
+    }
+
+    handlers.PlaylistsTemplate, err = template.New("playlists.html").Funcs(funcMap).ParseFiles("templates/playlists.html")
+    if err != nil {
+        log.Fatalf("Failed to parse playlists template: %v", err)
+    }
+
+    handlers.CollabPlaylistTemplate, err = template.New("collab-playlist.html").Funcs(funcMap).ParseFiles("templates/collab-playlist.html")
+    if err != nil {
+        log.Fatalf("Failed to parse collab-playlist template: %v", err)
+    }
+
+    handlers.HistoryTemplate, err = template.New("history.html").Funcs(funcMap).ParseFiles("templates/history.html")
+    if err != nil {
+        log.Fatalf("Failed to parse history template: %v", err)
+    }
+
+    handlers.SmartPlaylistsTemplate, err = template.New("smart.html").Funcs(funcMap).ParseFiles("templates/smart.html")
+    if err != nil {
+        log.Fatalf("Failed to parse smart template: %v", err)
+    }
+
+    handlers.SmartPlaylistTemplate, err = template.New("smart-playlist.html").Funcs(funcMap).ParseFiles("templates/smart-playlist.html")
+    if err != nil {
+        log.Fatalf("Failed to parse smart-playlist template: %v", err)
+    }
+
+    handlers.GenerateTemplate, err = template.New("generate.html").Funcs(funcMap).ParseFiles("templates/generate.html")
+    if err != nil {
+        log.Fatalf("Failed to parse generate template: %v", err)
+    }
+
+    handlers.SettingsTemplate, err = template.New("settings.html").Funcs(funcMap).ParseFiles("templates/settings.html")
+    if err != nil {
+        log.Fatalf("Failed to parse settings template: %v", err)
+    }
 }
 
 func main() {
@@ -87,17 +130,51 @@ func main() {
     http.HandleFunc("/lyrics", handlers.HandleLyrics)
     http.HandleFunc("/playlist", handlers.HandlePlaylist)
     http.HandleFunc("/playlist-lyrics", handlers.HandlePlaylistLyrics)
+    http.HandleFunc("/playlist/history", handlers.HandlePlaylistHistory)
+    http.HandleFunc("/playlist/undo", handlers.HandleUndoPlaylistChange)
+    http.HandleFunc("/playlist/restore", handlers.HandleRestorePlaylist)
+    http.HandleFunc("/playlist/trash/restore", handlers.HandleRestoreFromTrash)
+    http.HandleFunc("/playlist/shuffle", handlers.HandleShufflePlaylist)
+    http.HandleFunc("/playlist/songbook", handlers.HandleSongbook)
     http.HandleFunc("/add-to-playlist", handlers.HandleAddToPlaylist)
     http.HandleFunc("/remove-from-playlist", handlers.HandleRemoveFromPlaylist)
-    http.HandleFunc("/get-lyrics-text", handlers.HandleGetLyricsText)
+    http.HandleFunc("/lyrics/download", handlers.HandleDownloadLyrics)
+    http.HandleFunc("/lyrics/analysis", handlers.HandleLyricsAnalysis)
+    http.HandleFunc("/lyrics/upload-lrc", handlers.AuthMiddleware(handlers.HandleUploadLRC))
+    http.HandleFunc("/lyrics/sync/save", handlers.AuthMiddleware(handlers.HandleSaveSync))
+    http.HandleFunc("/lyrics/sync/offset", handlers.AuthMiddleware(handlers.HandleAdjustSyncOffset))
+    http.HandleFunc("/lyrics/chords/upload", handlers.AuthMiddleware(handlers.HandleUploadChords))
+    http.HandleFunc("/lyrics/chords/delete", handlers.AuthMiddleware(handlers.HandleDeleteChords))
     http.HandleFunc("/error", handlers.HandleError)
     http.HandleFunc("/faq", handlers.HandleFAQ)
     http.HandleFunc("/login", handlers.HandleLogin)
     http.HandleFunc("/register", handlers.HandleRegister)
     http.HandleFunc("/logout", handlers.HandleLogout) 
+    http.HandleFunc("/share/create", handlers.AuthMiddleware(handlers.HandleCreateShare))
+    http.HandleFunc("/share/revoke", handlers.AuthMiddleware(handlers.HandleRevokeShare))
+    http.HandleFunc("/shared/", handlers.HandleShared)
+    http.HandleFunc("/playlists", handlers.AuthMiddleware(handlers.HandlePlaylists))
+    http.HandleFunc("/playlists/create", handlers.AuthMiddleware(handlers.HandleCreatePlaylist))
+    http.HandleFunc("/playlists/view", handlers.AuthMiddleware(handlers.HandleViewPlaylist))
+    http.HandleFunc("/playlists/invite", handlers.AuthMiddleware(handlers.HandleInviteToPlaylist))
+    http.HandleFunc("/playlists/respond", handlers.AuthMiddleware(handlers.HandleRespondToInvite))
+    http.HandleFunc("/playlists/remove-member", handlers.AuthMiddleware(handlers.HandleRemoveMember))
+    http.HandleFunc("/playlists/remove-song", handlers.AuthMiddleware(handlers.HandleRemoveFromCollaborative))
+    http.HandleFunc("/playlists/combine", handlers.AuthMiddleware(handlers.HandleCombinePlaylists))
+    http.HandleFunc("/playlists/dedupe", handlers.HandleDedupePlaylist)
+    http.HandleFunc("/smart", handlers.AuthMiddleware(handlers.HandleSmartPlaylists))
+    http.HandleFunc("/smart/create", handlers.AuthMiddleware(handlers.HandleCreateSmartPlaylist))
+    http.HandleFunc("/smart/view", handlers.AuthMiddleware(handlers.HandleViewSmartPlaylist))
+    http.HandleFunc("/smart/delete", handlers.AuthMiddleware(handlers.HandleDeleteSmartPlaylist))
+    http.HandleFunc("/generate", handlers.AuthMiddleware(handlers.HandleGeneratePlaylist))
+    http.HandleFunc("/generate/save", handlers.AuthMiddleware(handlers.HandleSaveGeneratedPlaylist))
+    http.HandleFunc("/settings", handlers.AuthMiddleware(handlers.HandleSettings))
+
+    playlist.StartGuestCleanup(time.Hour)
 
     server := &http.Server{
         Addr:         ":8080",
+        Handler:      handlers.GuestMiddleware(http.DefaultServeMux),
         ReadTimeout:  10 * time.Second,
         WriteTimeout: 10 * time.Second,
     }
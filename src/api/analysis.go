@@ -0,0 +1,137 @@
+package api
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ReadingWordsPerMinute is the reading speed used for the estimated
+// reading time.
+const ReadingWordsPerMinute = 200
+
+// MaxTopWords caps how many frequent words an analysis lists.
+const MaxTopWords = 10
+
+// WordCount is a word and how often it occurs.
+type WordCount struct {
+	Word  string `json:"word"`
+	Count int    `json:"count"`
+}
+
+// LyricsAnalysis holds text statistics for a song's lyrics. Repeated
+// stanzas count every time they are sung. RepetitionScore is the share of
+// lines that repeat an earlier line, from 0 to 1.
+type LyricsAnalysis struct {
+	Words           int         `json:"words"`
+	UniqueWords     int         `json:"unique_words"`
+	UniqueRatio     float64     `json:"unique_ratio"`
+	TopWords        []WordCount `json:"top_words"`
+	Lines           int         `json:"lines"`
+	Stanzas         int         `json:"stanzas"`
+	RepeatedLines   int         `json:"repeated_lines"`
+	RepetitionScore float64     `json:"repetition_score"`
+	ReadingSeconds  int         `json:"reading_seconds"`
+}
+
+// stopwords are left out of the most frequent words. They are compared
+// after tokenizing, so contractions appear without their apostrophe.
+var stopwords = map[string]bool{}
+
+func init() {
+	for _, word := range strings.Fields(`
+		a about above after again against all am an and any are as at be
+		because been before being below between both but by can could did do
+		does doing down during each few for from further had has have having
+		he her here hers herself him himself his how i if in into is it its
+		itself just me more most my myself no nor not now of off on once only
+		or other our ours ourselves out over own same she should so some such
+		than that the their theirs them themselves then there these they this
+		those through to too under until up very was we were what when where
+		which while who whom why will with would you your yours yourself
+		yourselves im ive id ill youre youve youd youll hes shes weve
+		theyre theyve dont doesnt didnt cant couldnt wont wouldnt isnt arent
+		wasnt werent aint gonna wanna gotta got get oh ooh ah yeah hey la na
+		uh whoa like cause cuz em
+	`) {
+		stopwords[word] = true
+	}
+}
+
+// AnalyzeLyrics computes the statistics of lyrics.
+func AnalyzeLyrics(lyrics ParsedLyrics) LyricsAnalysis {
+	analysis := LyricsAnalysis{Stanzas: len(lyrics.Stanzas)}
+
+	counts := make(map[string]int)
+	seenLines := make(map[string]bool)
+	for _, stanza := range lyrics.Stanzas {
+		for _, line := range stanza.Lines {
+			tokens := tokenizeLyrics(line)
+			if len(tokens) == 0 {
+				continue
+			}
+			analysis.Lines++
+
+			terms := make([]string, len(tokens))
+			for i, token := range tokens {
+				terms[i] = token.term
+				counts[token.term]++
+			}
+			analysis.Words += len(tokens)
+
+			key := strings.Join(terms, " ")
+			if seenLines[key] {
+				analysis.RepeatedLines++
+			}
+			seenLines[key] = true
+		}
+	}
+
+	analysis.UniqueWords = len(counts)
+	if analysis.Words > 0 {
+		analysis.UniqueRatio = float64(analysis.UniqueWords) / float64(analysis.Words)
+		analysis.ReadingSeconds = (analysis.Words*60 + ReadingWordsPerMinute - 1) / ReadingWordsPerMinute
+	}
+	if analysis.Lines > 0 {
+		analysis.RepetitionScore = float64(analysis.RepeatedLines) / float64(analysis.Lines)
+	}
+
+	for word, count := range counts {
+		if stopwords[word] || len([]rune(word)) < 2 {
+			continue
+		}
+		analysis.TopWords = append(analysis.TopWords, WordCount{Word: word, Count: count})
+	}
+	sort.Slice(analysis.TopWords, func(i, j int) bool {
+		a, b := analysis.TopWords[i], analysis.TopWords[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Word < b.Word
+	})
+	if len(analysis.TopWords) > MaxTopWords {
+		analysis.TopWords = analysis.TopWords[:MaxTopWords]
+	}
+	return analysis
+}
+
+// UniquePercent is UniqueRatio as a whole percentage.
+func (a LyricsAnalysis) UniquePercent() int {
+	return int(a.UniqueRatio*100 + 0.5)
+}
+
+// RepetitionPercent is RepetitionScore as a whole percentage.
+func (a LyricsAnalysis) RepetitionPercent() int {
+	return int(a.RepetitionScore*100 + 0.5)
+}
+
+// FormattedReadingTime is the reading time as "1 min 20 s".
+func (a LyricsAnalysis) FormattedReadingTime() string {
+	if a.ReadingSeconds < 60 {
+		return fmt.Sprintf("%d s", a.ReadingSeconds)
+	}
+	if a.ReadingSeconds%60 == 0 {
+		return fmt.Sprintf("%d min", a.ReadingSeconds/60)
+	}
+	return fmt.Sprintf("%d min %d s", a.ReadingSeconds/60, a.ReadingSeconds%60)
+}
@@ -1,12 +1,12 @@
 package api
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
-	"sort"
 	"strings"
 	"time"
 
@@ -93,6 +93,7 @@ func SearchSpotifySongs(query string, page int, filters SearchFilters) ([]Song,
                     Name string `json:"name"`
                 } `json:"artists"`
                 Album struct {
+                    Name   string `json:"name"`
                     Images []struct {
                         URL string `json:"url"`
                     } `json:"images"`
@@ -122,6 +123,7 @@ func SearchSpotifySongs(query string, page int, filters SearchFilters) ([]Song,
             ID:          item.ID,
             Title:       item.Name,
             Artist:      artist,
+            Album:       item.Album.Name,
             Duration:    item.Duration,
             CoverURL:    coverURL,
             ReleaseDate: FormatReleaseDate(item.Album.ReleaseDate),
@@ -131,59 +133,15 @@ func SearchSpotifySongs(query string, page int, filters SearchFilters) ([]Song,
             continue
         }
 
-        if filters.LyricsFilter != "" {
-            hasLyrics := true 
-            if _, err := FetchLyricsOvh(song.Title, song.Artist); err != nil {
-                hasLyrics = false
-            }
-
-            switch filters.LyricsFilter {
-                case "with_lyrics":
-                    if !hasLyrics {
-                        continue
-                    }
-                case "without_lyrics":
-                    if hasLyrics {
-                        continue
-                    }
-            }
+        if !PassesLyricsFilter(song, filters.LyricsFilter) {
+            continue
         }
 
         songs = append(songs, song)
     }
+    songs = FilterByLanguage(songs, filters.Language)
 
-    switch filters.SortBy {
-    case "date":
-        if filters.SortOrder == "asc" {
-            sort.Slice(songs, func(i, j int) bool {
-                return songs[i].ReleaseDate.Before(songs[j].ReleaseDate)
-            })
-        } else {
-            sort.Slice(songs, func(i, j int) bool {
-                return songs[i].ReleaseDate.After(songs[j].ReleaseDate)
-            })
-        }
-    case "title":
-        if filters.SortOrder == "asc" {
-            sort.Slice(songs, func(i, j int) bool {
-                return strings.ToLower(songs[i].Title) < strings.ToLower(songs[j].Title)
-            })
-        } else {
-            sort.Slice(songs, func(i, j int) bool {
-                return strings.ToLower(songs[i].Title) > strings.ToLower(songs[j].Title)
-            })
-        }
-    case "artist":
-        if filters.SortOrder == "asc" {
-            sort.Slice(songs, func(i, j int) bool {
-                return strings.ToLower(songs[i].Artist) < strings.ToLower(songs[j].Artist)
-            })
-        } else {
-            sort.Slice(songs, func(i, j int) bool {
-                return strings.ToLower(songs[i].Artist) > strings.ToLower(songs[j].Artist)
-            })
-        }
-    }
+    SortSongs(songs, filters.SortBy, filters.SortOrder)
 
     totalResults := searchResp.Tracks.Total
     if totalResults > MaxTotalResults {
@@ -193,15 +151,26 @@ func SearchSpotifySongs(query string, page int, filters SearchFilters) ([]Song,
     return songs, totalResults, nil
 }
 
+// FetchLyricsOvh looks up lyrics for a song with a single request, for
+// exactly the given title and artist. FetchLyrics tries more forms of them.
 func FetchLyricsOvh(title, artist string) (string, error) {
-    sanitizedTitle := SanitizeSearchQuery(title)
-    
-    encodedTitle := url.QueryEscape(sanitizedTitle)
-    encodedArtist := url.QueryEscape(artist)
+    return fetchLyricsOvh(context.Background(), title, artist)
+}
+
+// fetchLyricsOvh queries lyrics.ovh with exactly the given title and artist.
+func fetchLyricsOvh(ctx context.Context, title, artist string) (string, error) {
+    encodedTitle := url.PathEscape(title)
+    encodedArtist := url.PathEscape(artist)
+
+    apiURL := fmt.Sprintf("%s/%s/%s", lyricsOvhURL, encodedArtist, encodedTitle)
 
-    apiURL := fmt.Sprintf("https://api.lyrics.ovh/v1/%s/%s", encodedArtist, encodedTitle)
+    req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+    if err != nil {
+        return "", err
+    }
 
-    resp, err := http.Get(apiURL)
+    client := &http.Client{Timeout: 10 * time.Second}
+    resp, err := client.Do(req)
     if err != nil {
         return "", err
     }
@@ -219,15 +188,11 @@ func FetchLyricsOvh(title, artist string) (string, error) {
         return "", err
     }
 
-    lyrics := strings.TrimSpace(lyricsResp.Lyrics)
+    lyrics := CleanLyrics(lyricsResp.Lyrics)
     if lyrics == "" {
         return "", fmt.Errorf("empty lyrics")
     }
 
-    if len(lyrics) > 5000 {
-        lyrics = lyrics[:5000] + "... (lyrics truncated)"
-    }
-
     return lyrics, nil
 }
 
@@ -334,5 +299,9 @@ func PassesFilters(song Song, filters SearchFilters) bool {
         return false
     }
 
+    if filters.Mood != "" && !song.HasMood(filters.Mood) {
+        return false
+    }
+
     return true
 }
@@ -16,7 +16,7 @@ func SanitizeSearchQuery(query string) string {
     re := regexp.MustCompile(`\s*\([^)]*\)`)
     query = re.ReplaceAllString(query, "")
 
-    parts := strings.Split(query, "-")
+    parts := strings.Split(query, " - ")
     query = strings.TrimSpace(parts[0])
 
     return strings.TrimSpace(query)
@@ -59,6 +59,14 @@ func DurationSeconds(duration int) int {
 }
 
 
+func FormatDurationMs(ms int) string {
+    seconds := ms / 1000
+    if seconds >= 3600 {
+        return fmt.Sprintf("%d:%02d:%02d", seconds/3600, (seconds%3600)/60, seconds%60)
+    }
+    return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
 func (s Song) FormattedDuration() string {
     seconds := s.Duration / 1000
     minutes := seconds / 60
@@ -96,5 +104,20 @@ func LoadConfig() error {
 		return fmt.Errorf("error parsing config file: %v", err)
 	}
 
+	// The Genius credentials live in config.json at the project root. They
+	// are optional: without them lyrics search only uses the local index.
+	geniusFile, err := os.Open("config.json")
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error opening config file: %v", err)
+	}
+	defer geniusFile.Close()
+
+	if err := json.NewDecoder(geniusFile).Decode(&geniusConfig); err != nil {
+		return fmt.Errorf("error parsing config file: %v", err)
+	}
+
 	return nil
 }
@@ -0,0 +1,452 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ChordSheetsDir holds uploaded ChordPro files, one per Spotify track ID.
+var ChordSheetsDir = "data/chords"
+
+// maxCapo is the highest capo position suggested.
+const maxCapo = 7
+
+// ChordSheet is a song in ChordPro format: lyrics with chords placed at
+// the syllable they are played on, split into sections.
+type ChordSheet struct {
+	Title    string
+	Subtitle string
+	Artist   string
+	Key      string
+	Capo     int
+	Tempo    string
+	Sections []ChordSection
+}
+
+// ChordSection is a verse, chorus, bridge or tab block, or a plain
+// paragraph when Kind is empty.
+type ChordSection struct {
+	Kind  string
+	Label string
+	Lines []ChordLine
+}
+
+// ChordLine is one line of a chord sheet, either lyrics with chords or a
+// comment such as "Repeat x2".
+type ChordLine struct {
+	Comment  string
+	Segments []ChordSegment
+}
+
+// ChordSegment is a chord and the lyrics sung from it up to the next
+// chord. Either may be empty.
+type ChordSegment struct {
+	Chord string
+	Text  string
+}
+
+// CapoSuggestion is a capo position and the chord shapes the song is
+// played with there. Easy counts how many of the song's distinct chords
+// become open shapes.
+type CapoSuggestion struct {
+	Capo   int
+	Shapes []string
+	Easy   int
+	Total  int
+}
+
+var (
+	ErrNoChords = errors.New("no chords found")
+
+	chordsMu sync.Mutex
+
+	chordPattern     = regexp.MustCompile(`^([A-G])([#b♯♭]?)(.*?)(?:/([A-G])([#b♯♭]?))?$`)
+	directivePattern = regexp.MustCompile(`^\{\s*([A-Za-z_]+)\s*(?:[: ]\s*(.*?))?\s*\}$`)
+	labelPattern     = regexp.MustCompile(`^label\s*=\s*"(.*)"$`)
+
+	sharpNotes = []string{"C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "B"}
+	flatNotes  = []string{"C", "Db", "D", "Eb", "E", "F", "Gb", "G", "Ab", "A", "Bb", "B"}
+
+	naturalNotes = map[string]int{"C": 0, "D": 2, "E": 4, "F": 5, "G": 7, "A": 9, "B": 11}
+
+	// flatMajorKeys and flatMinorKeys are the keys written with flats, by
+	// the semitone of their tonic.
+	flatMajorKeys = map[int]bool{1: true, 3: true, 5: true, 8: true, 10: true}
+	flatMinorKeys = map[int]bool{0: true, 2: true, 3: true, 5: true, 7: true, 10: true}
+
+	// openShapes are the chords a beginner plays without a barre.
+	openShapes = map[string]bool{
+		"C": true, "D": true, "E": true, "G": true, "A": true,
+		"Am": true, "Dm": true, "Em": true,
+		"A7": true, "B7": true, "C7": true, "D7": true, "E7": true, "G7": true,
+		"Am7": true, "Dm7": true, "Em7": true,
+		"Cmaj7": true, "Dmaj7": true, "Fmaj7": true, "Gmaj7": true, "Amaj7": true,
+		"Asus2": true, "Asus4": true, "Dsus2": true, "Dsus4": true, "Esus4": true,
+		"Cadd9": true, "Gadd9": true,
+	}
+
+	// sectionDirectives maps the directives that open a section, in their
+	// long and short forms, to the section kind.
+	sectionDirectives = map[string]string{
+		"start_of_verse": "verse", "sov": "verse",
+		"start_of_chorus": "chorus", "soc": "chorus",
+		"start_of_bridge": "bridge", "sob": "bridge",
+		"start_of_tab": "tab", "sot": "tab",
+	}
+	sectionEnds = map[string]bool{
+		"end_of_verse": true, "eov": true,
+		"end_of_chorus": true, "eoc": true,
+		"end_of_bridge": true, "eob": true,
+		"end_of_tab": true, "eot": true,
+	}
+)
+
+// ParseChordPro reads a chord sheet in ChordPro format. Lines starting
+// with # are ignored, blank lines outside a section start a new paragraph,
+// and unknown directives are skipped. It fails when the sheet has no
+// chords at all.
+func ParseChordPro(text string) (ChordSheet, error) {
+	var sheet ChordSheet
+	var current *ChordSection
+	flush := func() {
+		if current != nil && len(current.Lines) > 0 {
+			sheet.Sections = append(sheet.Sections, *current)
+		}
+		current = nil
+	}
+	section := func() *ChordSection {
+		if current == nil {
+			current = &ChordSection{}
+		}
+		return current
+	}
+
+	chords := 0
+	for _, raw := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		line := strings.TrimRight(strings.TrimPrefix(raw, "\ufeff"), " \t")
+		trimmed := strings.TrimSpace(line)
+
+		if current != nil && current.Kind == "tab" {
+			if m := directivePattern.FindStringSubmatch(trimmed); m != nil && sectionEnds[strings.ToLower(m[1])] {
+				flush()
+			} else {
+				current.Lines = append(current.Lines, ChordLine{Segments: []ChordSegment{{Text: line}}})
+			}
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(trimmed, "#"):
+			continue
+		case trimmed == "":
+			if current != nil && current.Kind == "" {
+				flush()
+			}
+			continue
+		}
+
+		m := directivePattern.FindStringSubmatch(trimmed)
+		if m == nil {
+			segments := parseChordLine(trimmed)
+			for _, segment := range segments {
+				if segment.Chord != "" {
+					chords++
+				}
+			}
+			section().Lines = append(section().Lines, ChordLine{Segments: segments})
+			continue
+		}
+
+		name, value := strings.ToLower(m[1]), m[2]
+		switch name {
+		case "title", "t":
+			sheet.Title = value
+		case "subtitle", "st":
+			sheet.Subtitle = value
+		case "artist":
+			sheet.Artist = value
+		case "key":
+			sheet.Key = value
+		case "capo":
+			sheet.Capo, _ = strconv.Atoi(value)
+		case "tempo":
+			sheet.Tempo = value
+		case "comment", "c", "comment_italic", "ci", "comment_box", "cb":
+			section().Lines = append(section().Lines, ChordLine{Comment: value})
+		case "chorus":
+			flush()
+			label := value
+			if label == "" {
+				label = "Chorus"
+			}
+			sheet.Sections = append(sheet.Sections, ChordSection{Kind: "chorus", Lines: []ChordLine{{Comment: label}}})
+		default:
+			if kind, ok := sectionDirectives[name]; ok {
+				flush()
+				if lm := labelPattern.FindStringSubmatch(value); lm != nil {
+					value = lm[1]
+				}
+				current = &ChordSection{Kind: kind, Label: value}
+			} else if sectionEnds[name] {
+				flush()
+			}
+		}
+	}
+	flush()
+
+	if chords == 0 {
+		return ChordSheet{}, ErrNoChords
+	}
+	return sheet, nil
+}
+
+// parseChordLine splits a lyrics line at its [chord] marks.
+func parseChordLine(line string) []ChordSegment {
+	var segments []ChordSegment
+	chord := ""
+	for {
+		open := strings.Index(line, "[")
+		end := strings.Index(line, "]")
+		if open < 0 || end < open {
+			break
+		}
+		if open > 0 || chord != "" {
+			segments = append(segments, ChordSegment{Chord: chord, Text: line[:open]})
+		}
+		chord = strings.TrimSpace(line[open+1 : end])
+		line = line[end+1:]
+	}
+	if line != "" || chord != "" {
+		segments = append(segments, ChordSegment{Chord: chord, Text: line})
+	}
+	return segments
+}
+
+// HasChords reports whether the line has any chord over it.
+func (l ChordLine) HasChords() bool {
+	for _, segment := range l.Segments {
+		if segment.Chord != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Chords lists the distinct chords of the sheet in the order they first
+// appear.
+func (s ChordSheet) Chords() []string {
+	var chords []string
+	seen := make(map[string]bool)
+	for _, section := range s.Sections {
+		for _, line := range section.Lines {
+			for _, segment := range line.Segments {
+				if segment.Chord != "" && !seen[segment.Chord] {
+					seen[segment.Chord] = true
+					chords = append(chords, segment.Chord)
+				}
+			}
+		}
+	}
+	return chords
+}
+
+// Transpose returns a copy of the sheet with every chord and the key moved
+// by semitones. accidentals is "sharp" or "flat" to spell the new chords
+// that way; otherwise they follow the convention of the new key. Chords
+// are left as written when there is nothing to change.
+func (s ChordSheet) Transpose(semitones int, accidentals string) ChordSheet {
+	semitones %= 12
+	if semitones == 0 && accidentals != "sharp" && accidentals != "flat" {
+		return s
+	}
+
+	flats := accidentals == "flat"
+	if accidentals != "sharp" && accidentals != "flat" {
+		key := s.Key
+		if key == "" {
+			if chords := s.Chords(); len(chords) > 0 {
+				key = chords[0]
+			}
+		}
+		if root, minor, ok := chordRoot(key); ok {
+			root = (root + semitones + 12) % 12
+			flats = (!minor && flatMajorKeys[root]) || (minor && flatMinorKeys[root])
+		}
+	}
+
+	transposed := s
+	if s.Key != "" {
+		transposed.Key = transposeChord(s.Key, semitones, flats)
+	}
+	transposed.Sections = make([]ChordSection, len(s.Sections))
+	for i, section := range s.Sections {
+		lines := make([]ChordLine, len(section.Lines))
+		for j, line := range section.Lines {
+			if section.Kind != "tab" && line.HasChords() {
+				segments := make([]ChordSegment, len(line.Segments))
+				for k, segment := range line.Segments {
+					segment.Chord = transposeChord(segment.Chord, semitones, flats)
+					segments[k] = segment
+				}
+				line.Segments = segments
+			}
+			lines[j] = line
+		}
+		section.Lines = lines
+		transposed.Sections[i] = section
+	}
+	return transposed
+}
+
+// SuggestCapo finds the capo positions that let the song be played with
+// the most open chord shapes, best first. It suggests nothing when the
+// song is already all open shapes, or when no capo position helps.
+func SuggestCapo(s ChordSheet) []CapoSuggestion {
+	chords := s.Chords()
+	if len(chords) == 0 {
+		return nil
+	}
+
+	var suggestions []CapoSuggestion
+	base := 0
+	for capo := 0; capo <= maxCapo; capo++ {
+		suggestion := CapoSuggestion{Capo: capo, Total: len(chords)}
+		for _, chord := range chords {
+			shape := transposeChord(chord, -capo, false)
+			suggestion.Shapes = append(suggestion.Shapes, shape)
+			if openShapes[strings.SplitN(shape, "/", 2)[0]] {
+				suggestion.Easy++
+			}
+		}
+		if capo == 0 {
+			base = suggestion.Easy
+			if base == len(chords) {
+				return nil
+			}
+			continue
+		}
+		if suggestion.Easy > base {
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].Easy > suggestions[j].Easy
+	})
+	if len(suggestions) > 3 {
+		suggestions = suggestions[:3]
+	}
+	return suggestions
+}
+
+// transposeChord moves a chord and its bass note by semitones. Anything
+// that does not read as a chord, such as "N.C.", is returned unchanged.
+func transposeChord(chord string, semitones int, flats bool) string {
+	m := chordPattern.FindStringSubmatch(chord)
+	if m == nil {
+		return chord
+	}
+	transposed := shiftNote(m[1], m[2], semitones, flats) + m[3]
+	if m[4] != "" {
+		transposed += "/" + shiftNote(m[4], m[5], semitones, flats)
+	}
+	return transposed
+}
+
+func shiftNote(letter, accidental string, semitones int, flats bool) string {
+	note := naturalNotes[letter]
+	switch accidental {
+	case "#", "♯":
+		note++
+	case "b", "♭":
+		note--
+	}
+	note = ((note+semitones)%12 + 12) % 12
+	if flats {
+		return flatNotes[note]
+	}
+	return sharpNotes[note]
+}
+
+// chordRoot returns the semitone of a chord's root and whether it is
+// minor.
+func chordRoot(chord string) (int, bool, bool) {
+	m := chordPattern.FindStringSubmatch(strings.TrimSpace(chord))
+	if m == nil {
+		return 0, false, false
+	}
+	root := shiftNote(m[1], m[2], 0, false)
+	for i, note := range sharpNotes {
+		if note == root {
+			minor := strings.HasPrefix(m[3], "m") && !strings.HasPrefix(m[3], "maj")
+			return i, minor, true
+		}
+	}
+	return 0, false, false
+}
+
+// LoadChordSheet reads the uploaded chord sheet for a track.
+func LoadChordSheet(songID string) (ChordSheet, error) {
+	path, err := chordSheetPath(songID)
+	if err != nil {
+		return ChordSheet{}, err
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ChordSheet{}, err
+	}
+	return ParseChordPro(string(data))
+}
+
+// SaveChordSheet stores text as the chord sheet for a track, replacing any
+// earlier upload. The text is kept as written, after checking that it
+// parses.
+func SaveChordSheet(songID, text string) error {
+	if _, err := ParseChordPro(text); err != nil {
+		return err
+	}
+	path, err := chordSheetPath(songID)
+	if err != nil {
+		return err
+	}
+
+	chordsMu.Lock()
+	defer chordsMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, []byte(text), 0644)
+}
+
+// DeleteChordSheet removes the chord sheet of a track, if it has one.
+func DeleteChordSheet(songID string) error {
+	path, err := chordSheetPath(songID)
+	if err != nil {
+		return err
+	}
+
+	chordsMu.Lock()
+	defer chordsMu.Unlock()
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func chordSheetPath(songID string) (string, error) {
+	if !validTrackID.MatchString(songID) {
+		return "", fmt.Errorf("invalid track id: %q", songID)
+	}
+	return filepath.Join(ChordSheetsDir, songID+".cho"), nil
+}
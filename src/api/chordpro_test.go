@@ -0,0 +1,154 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseChordProSegments(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want []ChordSegment
+	}{
+		{"chord first", "[G]Hello [C]world", []ChordSegment{{"G", "Hello "}, {"C", "world"}}},
+		{"text first", "Oh [Am]no", []ChordSegment{{"", "Oh "}, {"Am", "no"}}},
+		{"mid-word chord", "sun[D]shine", []ChordSegment{{"", "sun"}, {"D", "shine"}}},
+		{"trailing chord", "end [G]", []ChordSegment{{"", "end "}, {"G", ""}}},
+		{"slash chord", "[G/B]walk", []ChordSegment{{"G/B", "walk"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sheet, err := ParseChordPro(tt.line)
+			if err != nil {
+				t.Fatalf("ParseChordPro(%q): %v", tt.line, err)
+			}
+			if len(sheet.Sections) != 1 || len(sheet.Sections[0].Lines) != 1 {
+				t.Fatalf("ParseChordPro(%q) = %+v, want one line", tt.line, sheet.Sections)
+			}
+			if got := sheet.Sections[0].Lines[0].Segments; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseChordPro(%q) segments = %q, want %q", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseChordProDirectives(t *testing.T) {
+	sheet, err := ParseChordPro(`{title: Song}
+{st: Sub}
+{key: G}
+{capo: 2}
+# a comment
+{start_of_chorus: label="Refrain"}
+[G]La la
+{end_of_chorus}
+{start_of_tab}
+e|--0--|
+{end_of_tab}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sheet.Title != "Song" || sheet.Subtitle != "Sub" || sheet.Key != "G" || sheet.Capo != 2 {
+		t.Errorf("header = %q %q %q %d", sheet.Title, sheet.Subtitle, sheet.Key, sheet.Capo)
+	}
+	if len(sheet.Sections) != 2 {
+		t.Fatalf("got %d sections, want 2", len(sheet.Sections))
+	}
+	if s := sheet.Sections[0]; s.Kind != "chorus" || s.Label != "Refrain" {
+		t.Errorf("first section = %q %q, want chorus Refrain", s.Kind, s.Label)
+	}
+	if s := sheet.Sections[1]; s.Kind != "tab" || s.Lines[0].Segments[0].Text != "e|--0--|" {
+		t.Errorf("tab section = %+v", s)
+	}
+}
+
+func TestParseChordProNoChords(t *testing.T) {
+	if _, err := ParseChordPro("{title: Plain}\nJust words"); err != ErrNoChords {
+		t.Errorf("err = %v, want ErrNoChords", err)
+	}
+}
+
+func TestTranspose(t *testing.T) {
+	tests := []struct {
+		name        string
+		key         string
+		chords      string
+		semitones   int
+		accidentals string
+		wantKey     string
+		want        []string
+	}{
+		{"up a tone", "G", "[G][C][D]", 2, "", "A", []string{"A", "D", "E"}},
+		{"into a flat key", "C", "[C][F][G]", 3, "", "Eb", []string{"Eb", "Ab", "Bb"}},
+		{"into a sharp key", "F", "[F][Bb][C]", 1, "", "F#", []string{"F#", "B", "C#"}},
+		{"minor flat key", "Am", "[Am][Dm][E7]", -2, "", "Gm", []string{"Gm", "Cm", "D7"}},
+		{"forced sharps", "C", "[C][F]", 1, "sharp", "C#", []string{"C#", "F#"}},
+		{"forced flats", "C", "[C][F]", 1, "flat", "Db", []string{"Db", "Gb"}},
+		{"respell only", "", "[A#m][D#]", 0, "flat", "", []string{"Bbm", "Eb"}},
+		{"slash chords", "G", "[G/B][D/F#]", 2, "", "A", []string{"A/C#", "E/G#"}},
+		{"Cb and E#", "", "[Cb][E#]", 0, "sharp", "", []string{"B", "F"}},
+		{"down wraps", "C", "[C]", -1, "", "B", []string{"B"}},
+		{"full octave", "G", "[G7sus4]", 12, "", "G", []string{"G7sus4"}},
+		{"not a chord", "G", "[G][N.C.]", 2, "", "A", []string{"A", "N.C."}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sheet, err := ParseChordPro(tt.chords)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sheet.Key = tt.key
+			before := sheet.Chords()
+			got := sheet.Transpose(tt.semitones, tt.accidentals)
+			if got.Key != tt.wantKey {
+				t.Errorf("key = %q, want %q", got.Key, tt.wantKey)
+			}
+			if chords := got.Chords(); !reflect.DeepEqual(chords, tt.want) {
+				t.Errorf("chords = %q, want %q", chords, tt.want)
+			}
+			if after := sheet.Chords(); !reflect.DeepEqual(after, before) {
+				t.Errorf("Transpose changed the original sheet to %q", after)
+			}
+		})
+	}
+}
+
+func TestSuggestCapo(t *testing.T) {
+	tests := []struct {
+		name   string
+		chords string
+		want   []int
+	}{
+		{"barre chords", "[F][Bb][C][Dm]", []int{3, 5}},
+		{"all open already", "[G][C][D][Em]", nil},
+		{"sharp key, best first", "[F#][B][C#]", []int{4, 2, 6}},
+		{"no chords helped", "[N.C.][x]", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sheet, _ := ParseChordPro(tt.chords)
+			var got []int
+			for _, suggestion := range SuggestCapo(sheet) {
+				got = append(got, suggestion.Capo)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SuggestCapo(%s) capos = %v, want %v", tt.chords, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSuggestCapoShapes(t *testing.T) {
+	sheet, _ := ParseChordPro("[F][Bb][C][Dm]")
+	suggestions := SuggestCapo(sheet)
+	if len(suggestions) == 0 {
+		t.Fatal("no suggestions")
+	}
+	best := suggestions[0]
+	if want := []string{"D", "G", "A", "Bm"}; !reflect.DeepEqual(best.Shapes, want) {
+		t.Errorf("capo %d shapes = %q, want %q", best.Capo, best.Shapes, want)
+	}
+	if best.Easy != 3 || best.Total != 4 {
+		t.Errorf("capo %d easy = %d/%d, want 3/4", best.Capo, best.Easy, best.Total)
+	}
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// LyricsExport is everything needed to write a song's lyrics to a file.
+type LyricsExport struct {
+	ID     string       `json:"id"`
+	Title  string       `json:"title"`
+	Artist string       `json:"artist"`
+	Album  string       `json:"album,omitempty"`
+	Source string       `json:"source"`
+	Lyrics ParsedLyrics `json:"lyrics"`
+	Synced *TimedLyrics `json:"synced,omitempty"`
+
+	Analysis *LyricsAnalysis `json:"analysis,omitempty"`
+}
+
+// ExportFormats maps each supported download format to its content type.
+var ExportFormats = map[string]string{
+	"txt":  "text/plain; charset=utf-8",
+	"lrc":  "text/plain; charset=utf-8",
+	"md":   "text/markdown; charset=utf-8",
+	"json": "application/json",
+}
+
+var unsafeFilename = regexp.MustCompile(`[^\p{L}\p{N} .,&'_()-]+`)
+
+// Filename is "Artist - Title.ext" with characters that are unsafe in file
+// names removed.
+func (e LyricsExport) Filename(format string) string {
+	name := strings.TrimSpace(unsafeFilename.ReplaceAllString(e.Artist+" - "+e.Title, ""))
+	if name == "-" || name == "" {
+		name = e.ID
+	}
+	return name + "." + format
+}
+
+// Render writes the export in the given format.
+func (e LyricsExport) Render(format string) (string, error) {
+	switch format {
+	case "txt":
+		return e.Text(), nil
+	case "lrc":
+		return e.LRC()
+	case "md":
+		return e.Markdown(), nil
+	case "json":
+		if e.Analysis == nil {
+			analysis := AnalyzeLyrics(e.Lyrics)
+			e.Analysis = &analysis
+		}
+		data, err := json.MarshalIndent(e, "", "  ")
+		return string(data), err
+	}
+	return "", fmt.Errorf("unsupported format: %q", format)
+}
+
+// Text is a metadata header followed by the lyrics as fetched.
+func (e LyricsExport) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Title: %s\nArtist: %s\n", e.Title, e.Artist)
+	if e.Album != "" {
+		fmt.Fprintf(&b, "Album: %s\n", e.Album)
+	}
+	fmt.Fprintf(&b, "Source: %s\n\n", e.Source)
+	b.WriteString(e.Lyrics.Raw)
+	b.WriteString("\n")
+	return b.String()
+}
+
+// Markdown lists the metadata under the title and gives each labelled
+// stanza its own heading.
+func (e LyricsExport) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", e.Title)
+	fmt.Fprintf(&b, "- **Artist:** %s\n", e.Artist)
+	if e.Album != "" {
+		fmt.Fprintf(&b, "- **Album:** %s\n", e.Album)
+	}
+	fmt.Fprintf(&b, "- **Source:** %s\n", e.Source)
+
+	for _, stanza := range e.Lyrics.Stanzas {
+		b.WriteString("\n")
+		if stanza.Label != "" {
+			fmt.Fprintf(&b, "### %s\n\n", stanza.Label)
+		}
+		b.WriteString(strings.Join(stanza.Lines, "  \n"))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// LRC writes the synced lyrics with the song's metadata in the header.
+// It fails when the song has no synced lyrics.
+func (e LyricsExport) LRC() (string, error) {
+	if e.Synced == nil {
+		return "", ErrNoSyncedLyrics
+	}
+
+	synced := *e.Synced
+	synced.Tags = make(map[string]string)
+	for key, value := range e.Synced.Tags {
+		synced.Tags[key] = value
+	}
+	synced.Tags["ti"] = e.Title
+	synced.Tags["ar"] = e.Artist
+	if e.Album != "" {
+		synced.Tags["al"] = e.Album
+	}
+	synced.Tags["re"] = "Harmonify"
+	if synced.Source != "" {
+		synced.Tags["re"] += " (" + synced.Source + ")"
+	}
+	return FormatLRC(synced), nil
+}
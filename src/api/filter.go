@@ -0,0 +1,200 @@
+package api
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// LanguageCheckTimeout bounds the lyrics lookups FilterByLanguage makes
+	// for songs whose language is not known yet.
+	LanguageCheckTimeout = 4 * time.Second
+	// languageCheckers is how many of those lookups run at once.
+	languageCheckers = 5
+)
+
+func PassesLyricsFilter(song Song, lyricsFilter string) bool {
+	if lyricsFilter == "" {
+		return true
+	}
+
+	hasLyrics := true
+	if _, err := FetchLyricsOvh(song.Title, song.Artist); err != nil {
+		hasLyrics = false
+	}
+
+	switch lyricsFilter {
+	case "with_lyrics":
+		return hasLyrics
+	case "without_lyrics":
+		return !hasLyrics
+	}
+	return true
+}
+
+// PassesIndexedLyricsFilter is PassesLyricsFilter for saved songs: it asks
+// the lyrics index instead of the provider. Songs that are not indexed yet
+// are not filtered out.
+func PassesIndexedLyricsFilter(song Song, lyricsFilter string) bool {
+	if lyricsFilter == "" {
+		return true
+	}
+
+	hasLyrics, known := HasIndexedLyrics(song.ID)
+	if !known {
+		return true
+	}
+
+	switch lyricsFilter {
+	case "with_lyrics":
+		return hasLyrics
+	case "without_lyrics":
+		return !hasLyrics
+	}
+	return true
+}
+
+// PassesLanguageFilter reports whether the lyrics of song are in language,
+// going by its language tag or the lyrics index; nothing is fetched. Songs
+// that are not in the index are not filtered out, while indexed songs
+// without lyrics or with an unclear language never pass.
+func PassesLanguageFilter(song Song, language string) bool {
+	if language == "" {
+		return true
+	}
+	if song.Language != "" {
+		return song.Language == language
+	}
+	if indexed, ok := IndexedLanguage(song.ID); ok {
+		return indexed == language
+	}
+	_, known := HasIndexedLyrics(song.ID)
+	return !known
+}
+
+// FilterByLanguage keeps the songs whose lyrics are in language, for
+// search results that are mostly not saved. Songs are judged by their tag
+// or the lyrics index where possible; the rest have their lyrics looked up
+// with one request each, a few at a time, and their language detected
+// without storing anything. Songs still unknown after LanguageCheckTimeout
+// are kept, and songs without lyrics are left out.
+func FilterByLanguage(songs []Song, language string) []Song {
+	if language == "" {
+		return songs
+	}
+
+	keep := make([]bool, len(songs))
+	var unknown []int
+	for i, song := range songs {
+		_, known := HasIndexedLyrics(song.ID)
+		if song.Language != "" || known {
+			keep[i] = PassesLanguageFilter(song, language)
+		} else {
+			keep[i] = true
+			unknown = append(unknown, i)
+		}
+	}
+
+	if len(unknown) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), LanguageCheckTimeout)
+		defer cancel()
+
+		var wg sync.WaitGroup
+		slots := make(chan struct{}, languageCheckers)
+		for _, i := range unknown {
+			wg.Add(1)
+			go func(i int, song Song) {
+				defer wg.Done()
+				select {
+				case slots <- struct{}{}:
+					defer func() { <-slots }()
+				case <-ctx.Done():
+					return
+				}
+
+				lyrics, err := fetchLyricsOvh(ctx, song.Title, song.Artist)
+				if ctx.Err() != nil {
+					return
+				}
+				keep[i] = err == nil && DetectLyricsLanguage(ParseLyrics(lyrics)) == language
+			}(i, songs[i])
+		}
+		wg.Wait()
+	}
+
+	var kept []Song
+	for i, song := range songs {
+		if keep[i] {
+			kept = append(kept, song)
+		}
+	}
+	return kept
+}
+
+func MatchesText(song Song, text string) bool {
+	text = strings.ToLower(strings.TrimSpace(text))
+	if text == "" {
+		return true
+	}
+	return strings.Contains(strings.ToLower(song.Title), text) ||
+		strings.Contains(strings.ToLower(song.Artist), text)
+}
+
+func SortSongs(songs []Song, sortBy, sortOrder string) {
+	asc := sortOrder == "asc"
+
+	switch sortBy {
+	case "date":
+		sort.SliceStable(songs, func(i, j int) bool {
+			if asc {
+				return songs[i].ReleaseDate.Before(songs[j].ReleaseDate)
+			}
+			return songs[i].ReleaseDate.After(songs[j].ReleaseDate)
+		})
+	case "title":
+		sort.SliceStable(songs, func(i, j int) bool {
+			if asc {
+				return strings.ToLower(songs[i].Title) < strings.ToLower(songs[j].Title)
+			}
+			return strings.ToLower(songs[i].Title) > strings.ToLower(songs[j].Title)
+		})
+	case "artist":
+		sort.SliceStable(songs, func(i, j int) bool {
+			if asc {
+				return strings.ToLower(songs[i].Artist) < strings.ToLower(songs[j].Artist)
+			}
+			return strings.ToLower(songs[i].Artist) > strings.ToLower(songs[j].Artist)
+		})
+	case "duration":
+		sort.SliceStable(songs, func(i, j int) bool {
+			if asc {
+				return songs[i].Duration < songs[j].Duration
+			}
+			return songs[i].Duration > songs[j].Duration
+		})
+	}
+}
+
+// FilterSongs applies text search and SearchFilters to an already stored
+// list of songs, returning a sorted copy.
+func FilterSongs(songs []Song, text string, filters SearchFilters) []Song {
+	filtered := []Song{}
+	for _, song := range songs {
+		if !MatchesText(song, text) || !PassesFilters(song, filters) {
+			continue
+		}
+		if !PassesIndexedLyricsFilter(song, filters.LyricsFilter) {
+			continue
+		}
+		if !PassesLanguageFilter(song, filters.Language) {
+			continue
+		}
+		filtered = append(filtered, song)
+	}
+
+	SortSongs(filtered, filters.SortBy, filters.SortOrder)
+	return filtered
+}
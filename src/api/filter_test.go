@@ -0,0 +1,115 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+const (
+	englishLyrics = "I walked along the river in the morning light\nAnd every word you said to me was ringing in my mind\nWe were young and we were free and the summer never ended"
+	spanishLyrics = "Caminaba por la orilla del río con la luz de la mañana\nY cada palabra que dijiste sonaba en mi corazón\nÉramos jóvenes y libres y el verano nunca terminaba"
+)
+
+func TestFilterByLanguage(t *testing.T) {
+	useTempLyricsIndex(t)
+	useFakeLyricsOvh(t, map[string]fakeLyrics{
+		"Fetched English": {lyrics: englishLyrics},
+		"Fetched Spanish": {lyrics: spanishLyrics},
+		"Too Slow":        {delay: 2 * LanguageCheckTimeout, lyrics: englishLyrics},
+	})
+
+	spanish := ParseLyrics(spanishLyrics)
+	if err := IndexLyrics(Song{ID: "indexed-es"}, &spanish); err != nil {
+		t.Fatal(err)
+	}
+	if err := IndexLyrics(Song{ID: "indexed-none"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	songs := []Song{
+		{ID: "tagged-en", Title: "Tagged", Language: "en"},
+		{ID: "tagged-es", Title: "Tagged", Language: "es"},
+		{ID: "indexed-es", Title: "Indexed"},
+		{ID: "indexed-none", Title: "Indexed"},
+		{ID: "fetched-en", Title: "Fetched English"},
+		{ID: "fetched-es", Title: "Fetched Spanish"},
+		{ID: "no-lyrics", Title: "Nowhere"},
+		{ID: "slow", Title: "Too Slow"},
+	}
+
+	ids := func(songs []Song) []string {
+		var ids []string
+		for _, song := range songs {
+			ids = append(ids, song.ID)
+		}
+		return ids
+	}
+
+	start := time.Now()
+	got := ids(FilterByLanguage(songs, "es"))
+	if elapsed := time.Since(start); elapsed > LanguageCheckTimeout+time.Second {
+		t.Errorf("FilterByLanguage took %v, want at most about %v", elapsed, LanguageCheckTimeout)
+	}
+	if want := []string{"tagged-es", "indexed-es", "fetched-es", "slow"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FilterByLanguage(es) = %q, want %q", got, want)
+	}
+
+	if got := FilterByLanguage(songs, ""); len(got) != len(songs) {
+		t.Errorf("FilterByLanguage without a language kept %d of %d songs", len(got), len(songs))
+	}
+
+	if _, known := HasIndexedLyrics("fetched-es"); known {
+		t.Error("a search result was added to the lyrics index")
+	}
+}
+
+func TestFilterLyricsCandidates(t *testing.T) {
+	useTempLyricsIndex(t)
+	english := ParseLyrics(englishLyrics)
+	if err := IndexLyrics(Song{ID: "indexed-en"}, &english); err != nil {
+		t.Fatal(err)
+	}
+	if err := IndexLyrics(Song{ID: "indexed-none"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	mood := AnalyzeMood(english)
+	if mood.Sentiment == "" {
+		t.Fatal("test lyrics have no sentiment")
+	}
+
+	// Index matches only carry the ID, title and artist of a song.
+	candidates := []LyricsCandidate{
+		{LyricsMatch: LyricsMatch{Song: Song{ID: "indexed-en"}}, Source: "Harmonify"},
+		{LyricsMatch: LyricsMatch{Song: Song{ID: "indexed-none"}}, Source: "Harmonify"},
+		{LyricsMatch: LyricsMatch{Song: Song{ID: "old", ReleaseDate: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC), Duration: 200000}}, Source: "Genius"},
+		{LyricsMatch: LyricsMatch{Song: Song{ID: "new-es", ReleaseDate: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), Duration: 400000, Language: "es"}}, Source: "Genius"},
+	}
+
+	tests := []struct {
+		name    string
+		filters SearchFilters
+		want    []string
+	}{
+		{"no filters", SearchFilters{}, []string{"indexed-en", "indexed-none", "old", "new-es"}},
+		{"released since", SearchFilters{StartDate: "2000-01-01"}, []string{"indexed-en", "indexed-none", "new-es"}},
+		{"released until", SearchFilters{EndDate: "2000-01-01"}, []string{"indexed-en", "indexed-none", "old"}},
+		{"shorter than", SearchFilters{MaxDuration: 300000}, []string{"indexed-en", "indexed-none", "old"}},
+		{"with lyrics", SearchFilters{LyricsFilter: "with_lyrics"}, []string{"indexed-en", "old", "new-es"}},
+		{"without lyrics", SearchFilters{LyricsFilter: "without_lyrics"}, []string{"indexed-none", "old", "new-es"}},
+		{"language", SearchFilters{Language: "es"}, []string{"old", "new-es"}},
+		{"mood", SearchFilters{Mood: mood.Sentiment}, []string{"indexed-en"}},
+		{"sort order is left alone", SearchFilters{SortBy: "date"}, []string{"indexed-en", "indexed-none", "old", "new-es"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, candidate := range FilterLyricsCandidates(candidates, tt.filters) {
+				got = append(got, candidate.Song.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FilterLyricsCandidates = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	_ "embed"
+	"math"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// languageCorpus is sample text in every language the identifier knows,
+// bundled with the binary.
+//
+//go:embed languages.corpus
+var languageCorpus string
+
+const (
+	// minLanguageTrigrams is how many letter trigrams a text needs before
+	// its language is guessed at all.
+	minLanguageTrigrams = 30
+	// minLanguageMargin is how much better, in log-probability per
+	// trigram, the best language must score than the runner-up.
+	minLanguageMargin = 0.05
+)
+
+// Language is a language the identifier can recognize, by ISO 639-1 code.
+type Language struct {
+	Code string
+	Name string
+}
+
+type languageModel struct {
+	Language
+	counts map[string]int
+	total  int
+}
+
+var (
+	languageOnce   sync.Once
+	languageModels []*languageModel
+	languages      []Language
+	trigramKinds   int
+)
+
+// DetectLanguage guesses the language of text from its letter trigrams,
+// using a naive Bayes model trained on the bundled corpus. It returns the
+// language code, or "" when the text is too short or too close a call.
+func DetectLanguage(text string) string {
+	languageOnce.Do(loadLanguageModels)
+
+	grams := trigrams(text)
+	if len(grams) < minLanguageTrigrams {
+		return ""
+	}
+
+	best, second := math.Inf(-1), math.Inf(-1)
+	code := ""
+	for _, model := range languageModels {
+		score := 0.0
+		for _, gram := range grams {
+			score += math.Log(float64(model.counts[gram]+1) / float64(model.total+trigramKinds))
+		}
+		score /= float64(len(grams))
+		if score > best {
+			best, second, code = score, best, model.Code
+		} else if score > second {
+			second = score
+		}
+	}
+	if best-second < minLanguageMargin {
+		return ""
+	}
+	return code
+}
+
+// DetectLyricsLanguage guesses the language of lyrics, leaving out
+// repeated stanzas.
+func DetectLyricsLanguage(lyrics ParsedLyrics) string {
+	return DetectLanguage(strings.Join(searchableLines(lyrics), "\n"))
+}
+
+// Languages lists the recognized languages in the order of the corpus,
+// for filter menus.
+func Languages() []Language {
+	languageOnce.Do(loadLanguageModels)
+	return languages
+}
+
+// LanguageName returns the English name of a language code, or the code
+// itself when it is not recognized.
+func LanguageName(code string) string {
+	for _, language := range Languages() {
+		if language.Code == code {
+			return language.Name
+		}
+	}
+	return code
+}
+
+// trigrams splits text into words and returns the trigrams of each word
+// padded with a space on both sides, so word starts and endings count.
+// Letters are lower-cased but keep their accents.
+func trigrams(text string) []string {
+	var grams []string
+	for _, word := range strings.FieldsFunc(text, func(r rune) bool { return !unicode.IsLetter(r) }) {
+		runes := []rune(" " + strings.ToLower(word) + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			grams = append(grams, string(runes[i:i+3]))
+		}
+	}
+	return grams
+}
+
+func loadLanguageModels() {
+	kinds := make(map[string]bool)
+	var model *languageModel
+	for _, line := range strings.Split(languageCorpus, "\n") {
+		if strings.HasPrefix(line, ";;;") {
+			continue
+		}
+		if strings.HasPrefix(line, "@") {
+			code, name, _ := strings.Cut(strings.TrimPrefix(line, "@"), " ")
+			model = &languageModel{Language: Language{Code: code, Name: name}, counts: make(map[string]int)}
+			languageModels = append(languageModels, model)
+			languages = append(languages, model.Language)
+			continue
+		}
+		if model == nil {
+			continue
+		}
+		for _, gram := range trigrams(line) {
+			model.counts[gram]++
+			model.total++
+			kinds[gram] = true
+		}
+	}
+	trigramKinds = len(kinds)
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimedLine is one lyrics line and the moment it starts.
+type TimedLine struct {
+	Time time.Duration `json:"time"`
+	Text string        `json:"text"`
+}
+
+// TimedLyrics is a synchronized lyrics sheet. Tags holds the LRC header
+// fields such as "ti", "ar" and "al". Offset shifts every line, as the LRC
+// [offset:] tag does; a positive offset shows lines earlier.
+type TimedLyrics struct {
+	Tags   map[string]string `json:"tags,omitempty"`
+	Offset time.Duration     `json:"offset"`
+	Lines  []TimedLine       `json:"lines"`
+	Source string            `json:"source,omitempty"`
+}
+
+var (
+	ErrNoTimedLines = errors.New("no timed lines found")
+
+	lrcTimePattern = regexp.MustCompile(`^\[(\d{1,3}):(\d{1,2})(?:[.:](\d{1,3}))?\]`)
+	lrcTagPattern  = regexp.MustCompile(`^\[([A-Za-z#]+):(.*)\]$`)
+)
+
+// ParseLRC reads lyrics in LRC format. Lines may carry several timestamps
+// ("[00:12.00][01:30.50]text"); each one becomes its own timed line. The
+// result is sorted by time.
+func ParseLRC(text string) (TimedLyrics, error) {
+	lyrics := TimedLyrics{Tags: make(map[string]string)}
+
+	for _, raw := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		line := strings.TrimSpace(strings.TrimPrefix(raw, "\ufeff"))
+		if line == "" {
+			continue
+		}
+
+		var times []time.Duration
+		for {
+			m := lrcTimePattern.FindStringSubmatch(line)
+			if m == nil {
+				break
+			}
+			times = append(times, lrcTimestamp(m[1], m[2], m[3]))
+			line = line[len(m[0]):]
+		}
+
+		if len(times) == 0 {
+			if m := lrcTagPattern.FindStringSubmatch(line); m != nil {
+				key, value := strings.ToLower(m[1]), strings.TrimSpace(m[2])
+				if key == "offset" {
+					ms, _ := strconv.Atoi(strings.TrimPrefix(value, "+"))
+					lyrics.Offset = time.Duration(ms) * time.Millisecond
+					continue
+				}
+				lyrics.Tags[key] = value
+			}
+			continue
+		}
+
+		for _, t := range times {
+			lyrics.Lines = append(lyrics.Lines, TimedLine{Time: t, Text: strings.TrimSpace(line)})
+		}
+	}
+
+	if len(lyrics.Lines) == 0 {
+		return TimedLyrics{}, ErrNoTimedLines
+	}
+	sort.SliceStable(lyrics.Lines, func(i, j int) bool {
+		return lyrics.Lines[i].Time < lyrics.Lines[j].Time
+	})
+	return lyrics, nil
+}
+
+// FormatLRC writes lyrics back out in LRC format, header tags first.
+func FormatLRC(lyrics TimedLyrics) string {
+	var b strings.Builder
+
+	keys := make([]string, 0, len(lyrics.Tags))
+	for key := range lyrics.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&b, "[%s:%s]\n", key, lyrics.Tags[key])
+	}
+	if lyrics.Offset != 0 {
+		fmt.Fprintf(&b, "[offset:%+d]\n", lyrics.Offset.Milliseconds())
+	}
+
+	for _, line := range lyrics.Lines {
+		fmt.Fprintf(&b, "[%s]%s\n", FormatLRCTime(line.Time), line.Text)
+	}
+	return b.String()
+}
+
+// FormatLRCTime formats d as an LRC timestamp, "mm:ss.xx".
+func FormatLRCTime(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	cs := d.Milliseconds() / 10
+	return fmt.Sprintf("%02d:%02d.%02d", cs/6000, cs/100%60, cs%100)
+}
+
+// LineAt returns the index of the line showing at position t of the song,
+// or -1 before the first line.
+func (l TimedLyrics) LineAt(t time.Duration) int {
+	t += l.Offset
+	return sort.Search(len(l.Lines), func(i int) bool { return l.Lines[i].Time > t }) - 1
+}
+
+// PlainText returns the lyrics without timestamps.
+func (l TimedLyrics) PlainText() string {
+	lines := make([]string, len(l.Lines))
+	for i, line := range l.Lines {
+		lines[i] = line.Text
+	}
+	return strings.Join(lines, "\n")
+}
+
+func lrcTimestamp(min, sec, frac string) time.Duration {
+	m, _ := strconv.Atoi(min)
+	s, _ := strconv.Atoi(sec)
+	d := time.Duration(m)*time.Minute + time.Duration(s)*time.Second
+	if frac != "" {
+		f, _ := strconv.Atoi(frac)
+		for i := len(frac); i < 3; i++ {
+			f *= 10
+		}
+		d += time.Duration(f) * time.Millisecond
+	}
+	return d
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseLRC(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name   string
+		text   string
+		tags   map[string]string
+		offset time.Duration
+		lines  []TimedLine
+	}{
+		{
+			name:  "tags and lines",
+			text:  "[ti:Song]\n[ar: Someone ]\n[00:01.50]first\n[00:03.25] second ",
+			tags:  map[string]string{"ti": "Song", "ar": "Someone"},
+			lines: []TimedLine{{1500 * ms, "first"}, {3250 * ms, "second"}},
+		},
+		{
+			name:  "fraction precision",
+			text:  "[00:01.5]a\n[00:02.05]b\n[00:03.123]c\n[00:04]d\n[01:02:50]e",
+			tags:  map[string]string{},
+			lines: []TimedLine{{1500 * ms, "a"}, {2050 * ms, "b"}, {3123 * ms, "c"}, {4000 * ms, "d"}, {62500 * ms, "e"}},
+		},
+		{
+			name:  "several timestamps on a line are sorted",
+			text:  "[00:10.00][00:30.00]chorus\n[00:20.00]verse",
+			tags:  map[string]string{},
+			lines: []TimedLine{{10 * time.Second, "chorus"}, {20 * time.Second, "verse"}, {30 * time.Second, "chorus"}},
+		},
+		{
+			name:   "positive offset",
+			text:   "[offset:+500]\n[00:01.00]a",
+			tags:   map[string]string{},
+			offset: 500 * ms,
+			lines:  []TimedLine{{time.Second, "a"}},
+		},
+		{
+			name:   "negative offset",
+			text:   "[offset:-250]\r\n[00:01.00]a",
+			tags:   map[string]string{},
+			offset: -250 * ms,
+			lines:  []TimedLine{{time.Second, "a"}},
+		},
+		{
+			name:  "BOM and blank instrumental lines",
+			text:  "\ufeff[00:01.00]a\n\n[00:02.00]\n",
+			tags:  map[string]string{},
+			lines: []TimedLine{{time.Second, "a"}, {2 * time.Second, ""}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLRC(tt.text)
+			if err != nil {
+				t.Fatalf("ParseLRC: %v", err)
+			}
+			if !reflect.DeepEqual(got.Tags, tt.tags) {
+				t.Errorf("tags = %v, want %v", got.Tags, tt.tags)
+			}
+			if got.Offset != tt.offset {
+				t.Errorf("offset = %v, want %v", got.Offset, tt.offset)
+			}
+			if !reflect.DeepEqual(got.Lines, tt.lines) {
+				t.Errorf("lines = %v, want %v", got.Lines, tt.lines)
+			}
+		})
+	}
+}
+
+func TestParseLRCNoLines(t *testing.T) {
+	for _, text := range []string{"", "[ti:Only tags]", "plain lyrics\nwithout times"} {
+		if _, err := ParseLRC(text); err != ErrNoTimedLines {
+			t.Errorf("ParseLRC(%q) err = %v, want ErrNoTimedLines", text, err)
+		}
+	}
+}
+
+func TestLineAtOffset(t *testing.T) {
+	lyrics, err := ParseLRC("[00:01.00]a\n[00:02.00]b\n[00:03.00]c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		offset time.Duration
+		at     time.Duration
+		want   int
+	}{
+		{0, 500 * time.Millisecond, -1},
+		{0, time.Second, 0},
+		{0, 2500 * time.Millisecond, 1},
+		{0, time.Minute, 2},
+		{500 * time.Millisecond, 1500 * time.Millisecond, 1},
+		{-500 * time.Millisecond, 1200 * time.Millisecond, -1},
+	}
+	for _, tt := range tests {
+		lyrics.Offset = tt.offset
+		if got := lyrics.LineAt(tt.at); got != tt.want {
+			t.Errorf("offset %v: LineAt(%v) = %d, want %d", tt.offset, tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestFormatLRCRoundTrip(t *testing.T) {
+	text := "[ar:Someone]\n[ti:Song]\n[offset:-250]\n[00:01.50]first\n[01:02.05]second\n"
+	lyrics, err := ParseLRC(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := FormatLRC(lyrics); got != text {
+		t.Errorf("FormatLRC = %q, want %q", got, text)
+	}
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Stanza is one block of lyrics. Label holds a section heading such as
+// "Chorus" or "Verse 2" when the lyrics have one. A stanza that repeats an
+// earlier one has Repeat set and RepeatOf pointing at the first copy.
+type Stanza struct {
+	Label    string   `json:"label,omitempty"`
+	Lines    []string `json:"lines"`
+	Repeat   bool     `json:"repeat,omitempty"`
+	RepeatOf int      `json:"repeat_of,omitempty"`
+}
+
+// ParsedLyrics is the structure found in a lyrics text, kept next to the
+// raw text it came from.
+type ParsedLyrics struct {
+	Raw     string   `json:"raw"`
+	Stanzas []Stanza `json:"stanzas"`
+}
+
+var (
+	bracketLabelPattern = regexp.MustCompile(`^\[([^\]]+)\]$`)
+	plainLabelPattern   = regexp.MustCompile(`(?i)^\(?((intro|verse|pre-?chorus|chorus|post-?chorus|bridge|hook|refrain|interlude|outro|breakdown)(\s*\d+)?(\s*[x×]\s*\d+)?)\)?:?$`)
+	lyricsHeaderPattern = regexp.MustCompile(`(?i)^paroles de la chanson .+ par .+$`)
+	repeatCountPattern  = regexp.MustCompile(`(?i)\s*[x×]\s*\d+$`)
+)
+
+// CleanLyrics normalizes line endings and drops the "Paroles de la chanson"
+// header lyrics.ovh puts in front of some songs.
+func CleanLyrics(raw string) string {
+	raw = strings.ReplaceAll(raw, "\r\n", "\n")
+	raw = strings.ReplaceAll(raw, "\r", "\n")
+	lines := strings.Split(strings.TrimSpace(raw), "\n")
+	if len(lines) > 0 && lyricsHeaderPattern.MatchString(strings.TrimSpace(lines[0])) {
+		lines = lines[1:]
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// ParseLyrics splits lyrics into stanzas at blank lines and section labels,
+// and marks stanzas that repeat an earlier one. A label on its own, such
+// as a bare "[Chorus]", repeats the last stanza with the same label.
+func ParseLyrics(raw string) ParsedLyrics {
+	parsed := ParsedLyrics{Raw: CleanLyrics(raw)}
+
+	var current *Stanza
+	flush := func() {
+		if current != nil && (len(current.Lines) > 0 || current.Label != "") {
+			parsed.Stanzas = append(parsed.Stanzas, *current)
+		}
+		current = nil
+	}
+
+	for _, line := range strings.Split(parsed.Raw, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			flush()
+			continue
+		}
+		if label := SectionLabel(line); label != "" {
+			flush()
+			current = &Stanza{Label: label}
+			continue
+		}
+		if current == nil {
+			current = &Stanza{}
+		}
+		current.Lines = append(current.Lines, line)
+	}
+	flush()
+
+	markRepeats(parsed.Stanzas)
+	return parsed
+}
+
+// SectionLabel returns the section name if line is a heading like
+// "[Chorus]", "Verse 2:" or "(Bridge)", and "" otherwise.
+func SectionLabel(line string) string {
+	if m := bracketLabelPattern.FindStringSubmatch(line); m != nil {
+		return strings.TrimSpace(m[1])
+	}
+	if m := plainLabelPattern.FindStringSubmatch(line); m != nil {
+		return strings.TrimSpace(m[1])
+	}
+	return ""
+}
+
+func markRepeats(stanzas []Stanza) {
+	byText := make(map[string]int)
+	byLabel := make(map[string]int)
+
+	for i := range stanzas {
+		s := &stanzas[i]
+		labelKey := sectionKey(s.Label)
+
+		if len(s.Lines) == 0 {
+			if first, ok := byLabel[labelKey]; ok && labelKey != "" {
+				s.Lines = append([]string(nil), stanzas[first].Lines...)
+				s.Repeat, s.RepeatOf = true, first
+			}
+			continue
+		}
+
+		textKey := NormalizeText(strings.Join(s.Lines, "\n"))
+		if first, ok := byText[textKey]; ok {
+			s.Repeat, s.RepeatOf = true, first
+			if s.Label == "" {
+				s.Label = stanzas[first].Label
+			}
+			continue
+		}
+		byText[textKey] = i
+		if labelKey != "" {
+			if _, ok := byLabel[labelKey]; !ok {
+				byLabel[labelKey] = i
+			}
+		}
+	}
+}
+
+// sectionKey drops repeat counts and colons so "Chorus x2" and "Chorus:"
+// refer to the same section as "Chorus".
+func sectionKey(label string) string {
+	label = repeatCountPattern.ReplaceAllString(label, "")
+	return NormalizeText(label)
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCleanLyrics(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"a\r\nb\rc", "a\nb\nc"},
+		{"Paroles de la chanson Yesterday par The Beatles\r\nYesterday", "Yesterday"},
+		{"\n\n  line  \n\n", "line"},
+	}
+	for _, tt := range tests {
+		if got := CleanLyrics(tt.raw); got != tt.want {
+			t.Errorf("CleanLyrics(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestSectionLabel(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"[Chorus]", "Chorus"},
+		{"[Verse 2: Someone]", "Verse 2: Someone"},
+		{"Verse 2:", "Verse 2"},
+		{"(Bridge)", "Bridge"},
+		{"Chorus x2", "Chorus x2"},
+		{"pre-chorus", "pre-chorus"},
+		{"Chorus of angels sing", ""},
+		{"I love you", ""},
+	}
+	for _, tt := range tests {
+		if got := SectionLabel(tt.line); got != tt.want {
+			t.Errorf("SectionLabel(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestParseLyrics(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want []Stanza
+	}{
+		{
+			name: "blank lines split stanzas",
+			raw:  "one\ntwo\n\n\nthree",
+			want: []Stanza{{Lines: []string{"one", "two"}}, {Lines: []string{"three"}}},
+		},
+		{
+			name: "labels start stanzas",
+			raw:  "[Verse 1]\na\nb\n[Chorus]\nc",
+			want: []Stanza{{Label: "Verse 1", Lines: []string{"a", "b"}}, {Label: "Chorus", Lines: []string{"c"}}},
+		},
+		{
+			name: "same text repeats and takes the label",
+			raw:  "[Chorus]\nla la\n\nother words\n\nLa la!",
+			want: []Stanza{
+				{Label: "Chorus", Lines: []string{"la la"}},
+				{Lines: []string{"other words"}},
+				{Label: "Chorus", Lines: []string{"La la!"}, Repeat: true, RepeatOf: 0},
+			},
+		},
+		{
+			name: "bare label repeats the section",
+			raw:  "[Chorus]\nhey\n[Verse]\nho\n[Chorus x2]",
+			want: []Stanza{
+				{Label: "Chorus", Lines: []string{"hey"}},
+				{Label: "Verse", Lines: []string{"ho"}},
+				{Label: "Chorus x2", Lines: []string{"hey"}, Repeat: true, RepeatOf: 0},
+			},
+		},
+		{
+			name: "bare label with nothing before it",
+			raw:  "[Intro]\n\n[Verse]\nwords",
+			want: []Stanza{{Label: "Intro"}, {Label: "Verse", Lines: []string{"words"}}},
+		},
+		{
+			name: "empty",
+			raw:  "  \n ",
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseLyrics(tt.raw)
+			if !reflect.DeepEqual(got.Stanzas, tt.want) {
+				t.Errorf("ParseLyrics(%q) stanzas =\n%+v\nwant\n%+v", tt.raw, got.Stanzas, tt.want)
+			}
+			if got.Raw != CleanLyrics(tt.raw) {
+				t.Errorf("Raw = %q, want the cleaned text", got.Raw)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var geniusSearchURL = "https://api.genius.com/search"
+
+const (
+	// MaxGeniusHits caps how many Genius results are looked up on Spotify.
+	MaxGeniusHits = 10
+	// VerifiedGeniusHits is how many of the top Genius results get their
+	// lyrics fetched, to show the matched line and to feed the local index.
+	VerifiedGeniusHits = 3
+	// LyricsSearchTimeout bounds a whole FindSongsByLyrics call. Genius
+	// results that are not found on Spotify by then are left out.
+	LyricsSearchTimeout = 8 * time.Second
+)
+
+var ErrNoGeniusToken = errors.New("no Genius access token configured")
+
+// LyricsCandidate is a song that may contain a remembered lyric fragment.
+// Lines holds the matched lines when the lyrics are known; Source says
+// where the song was found.
+type LyricsCandidate struct {
+	LyricsMatch
+	Source string
+}
+
+type geniusHit struct {
+	Title  string
+	Artist string
+}
+
+// FindSongsByLyrics returns songs whose lyrics may contain fragment: first
+// the ones in the local lyrics index, then Genius search results found on
+// Spotify. When Genius cannot be asked, the local results are returned
+// together with the error.
+func FindSongsByLyrics(ctx context.Context, fragment string) ([]LyricsCandidate, error) {
+	ctx, cancel := context.WithTimeout(ctx, LyricsSearchTimeout)
+	defer cancel()
+
+	var candidates []LyricsCandidate
+	seen := make(map[string]bool)
+	add := func(candidate LyricsCandidate) {
+		key := lyricsQueryKey(candidate.Song.Title, candidate.Song.Artist)
+		if seen[candidate.Song.ID] || seen[key] {
+			return
+		}
+		seen[candidate.Song.ID], seen[key] = true, true
+		candidates = append(candidates, candidate)
+	}
+
+	for _, match := range SearchLyricsIndex(fragment) {
+		add(LyricsCandidate{LyricsMatch: match, Source: "Harmonify"})
+	}
+
+	hits, err := searchGenius(ctx, fragment)
+	if err != nil {
+		return candidates, err
+	}
+	if len(hits) > MaxGeniusHits {
+		hits = hits[:MaxGeniusHits]
+	}
+
+	type resolvedHit struct {
+		index     int
+		candidate *LyricsCandidate
+	}
+	results := make(chan resolvedHit, len(hits))
+	for i, hit := range hits {
+		go func(i int, hit geniusHit) {
+			results <- resolvedHit{i, resolveGeniusHit(ctx, hit, fragment, i < VerifiedGeniusHits)}
+		}(i, hit)
+	}
+
+	resolved := make([]*LyricsCandidate, len(hits))
+collect:
+	for range hits {
+		select {
+		case result := <-results:
+			resolved[result.index] = result.candidate
+		case <-ctx.Done():
+			break collect
+		}
+	}
+
+	for _, candidate := range resolved {
+		if candidate != nil {
+			add(*candidate)
+		}
+	}
+	return candidates, nil
+}
+
+// FilterLyricsCandidates applies SearchFilters to the results of
+// FindSongsByLyrics, keeping their order. Songs found in the lyrics index
+// only carry an ID, title and artist, so release date and duration limits
+// let them through; their mood comes from the indexed lyrics.
+func FilterLyricsCandidates(candidates []LyricsCandidate, filters SearchFilters) []LyricsCandidate {
+	var filtered []LyricsCandidate
+	for _, candidate := range candidates {
+		song, check := candidate.Song, filters
+		if song.ReleaseDate.IsZero() {
+			check.StartDate, check.EndDate = "", ""
+		}
+		if song.Duration == 0 {
+			check.MinDuration, check.MaxDuration = 0, 0
+		}
+		if mood, ok := IndexedMood(song.ID); ok && song.Sentiment == "" {
+			song.Sentiment, song.Moods = mood.Sentiment, mood.Tags
+		}
+
+		if !PassesFilters(song, check) || !PassesIndexedLyricsFilter(song, filters.LyricsFilter) {
+			continue
+		}
+		if !PassesLanguageFilter(song, filters.Language) {
+			continue
+		}
+		filtered = append(filtered, candidate)
+	}
+	return filtered
+}
+
+// resolveGeniusHit finds the Spotify track for a Genius result. With
+// verify set, it also fetches the lyrics to find the matched lines and
+// stores them in the lyrics index.
+func resolveGeniusHit(ctx context.Context, hit geniusHit, fragment string, verify bool) *LyricsCandidate {
+	songs, _, err := SearchSpotifySongs(fmt.Sprintf("track:%s artist:%s", hit.Title, hit.Artist), 1, SearchFilters{})
+	if err != nil || len(songs) == 0 {
+		return nil
+	}
+	candidate := &LyricsCandidate{LyricsMatch: LyricsMatch{Song: songs[0]}, Source: "Genius"}
+	if !verify {
+		return candidate
+	}
+
+	result, err := FetchLyrics(ctx, candidate.Song.Title, candidate.Song.Artist)
+	if err != nil {
+		return candidate
+	}
+	if NeedsLyricsIndex(candidate.Song) {
+		if err := IndexLyrics(candidate.Song, &result.Parsed); err != nil {
+			log.Printf("Error indexing lyrics for %s: %v", candidate.Song.ID, err)
+		}
+	}
+	match := MatchLyrics(result.Parsed, fragment)
+	candidate.Lines, candidate.More = match.Lines, match.More
+	return candidate
+}
+
+// searchGenius runs fragment through Genius search, which matches lyrics
+// as well as titles, and returns the song results in Genius' order.
+func searchGenius(ctx context.Context, fragment string) ([]geniusHit, error) {
+	if geniusConfig.AccessToken == "" {
+		return nil, ErrNoGeniusToken
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", geniusSearchURL+"?q="+url.QueryEscape(fragment), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Genius request: %v", err)
+	}
+	req.Header.Add("Authorization", "Bearer "+geniusConfig.AccessToken)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search Genius: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Genius API returned non-200 status: %v", resp.StatusCode)
+	}
+
+	var searchResp struct {
+		Response struct {
+			Hits []struct {
+				Type   string `json:"type"`
+				Result struct {
+					Title         string `json:"title"`
+					PrimaryArtist struct {
+						Name string `json:"name"`
+					} `json:"primary_artist"`
+				} `json:"result"`
+			} `json:"hits"`
+		} `json:"response"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&searchResp); err != nil {
+		return nil, fmt.Errorf("failed to decode Genius response: %v", err)
+	}
+
+	var hits []geniusHit
+	for _, hit := range searchResp.Response.Hits {
+		if hit.Type != "song" {
+			continue
+		}
+		hits = append(hits, geniusHit{
+			Title:  cleanGeniusText(hit.Result.Title),
+			Artist: cleanGeniusText(hit.Result.PrimaryArtist.Name),
+		})
+	}
+	return hits, nil
+}
+
+// cleanGeniusText drops the zero-width and non-breaking spaces Genius
+// leaves in some titles and names.
+func cleanGeniusText(s string) string {
+	s = strings.ReplaceAll(s, "\u200b", "")
+	s = strings.ReplaceAll(s, "\u00a0", " ")
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,539 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// LyricsIndexFile holds the lyrics of every saved song, and of songs whose
+// lyrics were looked up before, one entry per song ID, so songs can be
+// found by what they say.
+var LyricsIndexFile = "data/lyrics_index.json"
+
+// LyricsRetryAfter is how long a song whose lyrics could not be found is
+// left alone before the index asks the provider again.
+const LyricsRetryAfter = 24 * time.Hour
+
+// MaxMatchedLines caps how many matching lines are shown per song.
+const MaxMatchedLines = 3
+
+// lyricsIndexSaveDelay is how long changes to the index are collected
+// before the file is written, so indexing a playlist writes it once rather
+// than once per song.
+const lyricsIndexSaveDelay = 2 * time.Second
+
+// IndexedLyrics is one song's entry in the lyrics index. Raw is the lyrics
+// text as fetched, kept so the stanzas can be rebuilt; Lines are the lines
+// searched, without repeated stanzas. Language is the detected language
+// code, if any. Missing is set when the provider had no lyrics for it at
+// IndexedAt.
+type IndexedLyrics struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Artist    string    `json:"artist"`
+	Raw       string    `json:"raw,omitempty"`
+	Lines     []string  `json:"lines,omitempty"`
+	Language  string    `json:"language,omitempty"`
+	Missing   bool      `json:"missing,omitempty"`
+	IndexedAt time.Time `json:"indexed_at"`
+}
+
+// LineSegment is a piece of a lyrics line, marked when it is part of the
+// searched phrase.
+type LineSegment struct {
+	Text  string
+	Match bool
+}
+
+// LyricsMatch is a song whose lyrics contain the searched phrase, with the
+// lines it was found on. More counts the matching lines left out.
+type LyricsMatch struct {
+	Song  Song
+	Lines [][]LineSegment
+	More  int
+}
+
+type lyricsToken struct {
+	term       string
+	start, end int
+}
+
+var lyricsIndex struct {
+	sync.RWMutex
+	loaded   bool
+	docs     map[string]*IndexedLyrics
+	postings map[string]map[string]bool
+	counts   map[string]map[string]int
+
+	// vectors caches the TF-IDF vector of each song for SimilarLyrics.
+	// Every write to the index changes the weights, so post and unpost
+	// drop the whole cache; vectorsMu guards filling it under a read lock.
+	vectors   map[string]map[string]float64
+	vectorsMu sync.Mutex
+
+	dirty     bool
+	saveTimer *time.Timer
+}
+
+// NeedsLyricsIndex reports whether song has no entry in the index yet, or
+// only a missing-lyrics entry older than LyricsRetryAfter.
+func NeedsLyricsIndex(song Song) bool {
+	if err := loadLyricsIndex(); err != nil {
+		return false
+	}
+	lyricsIndex.RLock()
+	defer lyricsIndex.RUnlock()
+
+	doc, ok := lyricsIndex.docs[song.ID]
+	if !ok {
+		return true
+	}
+	return doc.Missing && time.Since(doc.IndexedAt) > LyricsRetryAfter
+}
+
+// HasIndexedLyrics reports whether the index has lyrics for a song. known
+// is false when the song has not been indexed yet.
+func HasIndexedLyrics(songID string) (has, known bool) {
+	if err := loadLyricsIndex(); err != nil {
+		return false, false
+	}
+	lyricsIndex.RLock()
+	defer lyricsIndex.RUnlock()
+
+	doc, ok := lyricsIndex.docs[songID]
+	if !ok {
+		return false, false
+	}
+	return !doc.Missing, true
+}
+
+// IndexedLyricsOf returns the indexed lyrics of a song, parsed again from
+// the stored text. known is false when the song is not indexed, or was
+// indexed before the text was kept; lyrics is nil when it is known to have
+// no lyrics.
+func IndexedLyricsOf(songID string) (lyrics *ParsedLyrics, known bool) {
+	if err := loadLyricsIndex(); err != nil {
+		return nil, false
+	}
+	lyricsIndex.RLock()
+	defer lyricsIndex.RUnlock()
+
+	doc, ok := lyricsIndex.docs[songID]
+	if !ok {
+		return nil, false
+	}
+	if doc.Missing {
+		return nil, true
+	}
+	if doc.Raw == "" {
+		return nil, false
+	}
+	parsed := ParseLyrics(doc.Raw)
+	return &parsed, true
+}
+
+// IndexedMood scores the indexed lyrics of a song. It fails when the song
+// is not indexed or has no lyrics.
+func IndexedMood(songID string) (Mood, bool) {
+	if err := loadLyricsIndex(); err != nil {
+		return Mood{}, false
+	}
+	lyricsIndex.RLock()
+	defer lyricsIndex.RUnlock()
+
+	doc, ok := lyricsIndex.docs[songID]
+	if !ok || doc.Missing {
+		return Mood{}, false
+	}
+	return moodOfLines(doc.Lines), true
+}
+
+// IndexedLanguage returns the detected language of the indexed lyrics of
+// a song. It fails when the song is not indexed or has no lyrics; the
+// language may still be "" when it was unclear.
+func IndexedLanguage(songID string) (string, bool) {
+	if err := loadLyricsIndex(); err != nil {
+		return "", false
+	}
+	lyricsIndex.RLock()
+	defer lyricsIndex.RUnlock()
+
+	doc, ok := lyricsIndex.docs[songID]
+	if !ok || doc.Missing {
+		return "", false
+	}
+	return doc.Language, true
+}
+
+// IndexLyrics stores the lyrics of song in the index, replacing any earlier
+// entry. A nil lyrics records the song as having no lyrics. The index file
+// is written shortly after, together with any other changes made in the
+// meantime; FlushLyricsIndex writes it straight away.
+func IndexLyrics(song Song, lyrics *ParsedLyrics) error {
+	doc := &IndexedLyrics{
+		ID:        song.ID,
+		Title:     song.Title,
+		Artist:    song.Artist,
+		Missing:   lyrics == nil,
+		IndexedAt: time.Now(),
+	}
+	if lyrics != nil {
+		doc.Raw = lyrics.Raw
+		doc.Lines = searchableLines(*lyrics)
+		doc.Language = DetectLyricsLanguage(*lyrics)
+	}
+
+	if err := loadLyricsIndex(); err != nil {
+		return err
+	}
+	lyricsIndex.Lock()
+	defer lyricsIndex.Unlock()
+
+	unpost(song.ID)
+	lyricsIndex.docs[song.ID] = doc
+	post(doc)
+	scheduleLyricsIndexSave()
+	return nil
+}
+
+// UnindexLyrics drops a song from the index.
+func UnindexLyrics(songID string) error {
+	if err := loadLyricsIndex(); err != nil {
+		return err
+	}
+	lyricsIndex.Lock()
+	defer lyricsIndex.Unlock()
+
+	if _, ok := lyricsIndex.docs[songID]; !ok {
+		return nil
+	}
+	unpost(songID)
+	delete(lyricsIndex.docs, songID)
+	scheduleLyricsIndexSave()
+	return nil
+}
+
+// FlushLyricsIndex writes any index changes that are waiting to be saved.
+func FlushLyricsIndex() error {
+	lyricsIndex.Lock()
+	defer lyricsIndex.Unlock()
+
+	if lyricsIndex.saveTimer != nil {
+		lyricsIndex.saveTimer.Stop()
+		lyricsIndex.saveTimer = nil
+	}
+	if !lyricsIndex.dirty {
+		return nil
+	}
+	if err := saveLyricsIndex(); err != nil {
+		return err
+	}
+	lyricsIndex.dirty = false
+	return nil
+}
+
+// scheduleLyricsIndexSave marks the index as changed and saves it after
+// lyricsIndexSaveDelay, unless a save is already on its way. The caller
+// must hold lyricsIndex for writing.
+func scheduleLyricsIndexSave() {
+	lyricsIndex.dirty = true
+	if lyricsIndex.saveTimer != nil {
+		return
+	}
+	lyricsIndex.saveTimer = time.AfterFunc(lyricsIndexSaveDelay, func() {
+		if err := FlushLyricsIndex(); err != nil {
+			log.Printf("Error saving the lyrics index: %v", err)
+		}
+	})
+}
+
+// SearchLyrics returns the songs among songs whose lyrics contain phrase on
+// a single line, in the order of songs. Matching ignores case, accents and
+// punctuation.
+func SearchLyrics(songs []Song, phrase string) []LyricsMatch {
+	query := tokenizeLyrics(phrase)
+	if len(query) == 0 {
+		return nil
+	}
+	if err := loadLyricsIndex(); err != nil {
+		return nil
+	}
+	lyricsIndex.RLock()
+	defer lyricsIndex.RUnlock()
+
+	var matches []LyricsMatch
+	for _, song := range songs {
+		doc, ok := lyricsIndex.docs[song.ID]
+		if !ok || !containsAllTerms(song.ID, query) {
+			continue
+		}
+		if match := matchLines(doc.Lines, query); len(match.Lines) > 0 {
+			match.Song = song
+			matches = append(matches, match)
+		}
+	}
+	return matches
+}
+
+// SearchLyricsIndex looks for phrase in every song of the index, not just
+// a given list, and returns the matches with the most matching lines
+// first. The songs only carry the ID, title and artist they were indexed
+// with.
+func SearchLyricsIndex(phrase string) []LyricsMatch {
+	query := tokenizeLyrics(phrase)
+	if len(query) == 0 {
+		return nil
+	}
+	if err := loadLyricsIndex(); err != nil {
+		return nil
+	}
+	lyricsIndex.RLock()
+	defer lyricsIndex.RUnlock()
+
+	var matches []LyricsMatch
+	for id := range lyricsIndex.postings[query[0].term] {
+		doc := lyricsIndex.docs[id]
+		if !containsAllTerms(id, query) {
+			continue
+		}
+		if match := matchLines(doc.Lines, query); len(match.Lines) > 0 {
+			match.Song = Song{ID: doc.ID, Title: doc.Title, Artist: doc.Artist}
+			matches = append(matches, match)
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Song.ID < matches[j].Song.ID
+	})
+	SortByMatches(matches)
+	return matches
+}
+
+// MatchLyrics finds phrase in lyrics that are not in the index. The match
+// has no Song set and no Lines when the phrase does not occur.
+func MatchLyrics(lyrics ParsedLyrics, phrase string) LyricsMatch {
+	query := tokenizeLyrics(phrase)
+	if len(query) == 0 {
+		return LyricsMatch{}
+	}
+	return matchLines(searchableLines(lyrics), query)
+}
+
+// searchableLines leaves out repeated stanzas so a chorus only matches
+// once.
+func searchableLines(lyrics ParsedLyrics) []string {
+	var lines []string
+	for _, stanza := range lyrics.Stanzas {
+		if !stanza.Repeat {
+			lines = append(lines, stanza.Lines...)
+		}
+	}
+	return lines
+}
+
+func matchLines(lines []string, query []lyricsToken) LyricsMatch {
+	var match LyricsMatch
+	for _, line := range lines {
+		spans := phraseSpans(tokenizeLyrics(line), query)
+		if len(spans) == 0 {
+			continue
+		}
+		if len(match.Lines) < MaxMatchedLines {
+			match.Lines = append(match.Lines, highlight(line, spans))
+		} else {
+			match.More++
+		}
+	}
+	return match
+}
+
+// SortByMatches orders matches so the songs with the most matching lines
+// come first.
+func SortByMatches(matches []LyricsMatch) {
+	sort.SliceStable(matches, func(i, j int) bool {
+		return len(matches[i].Lines)+matches[i].More > len(matches[j].Lines)+matches[j].More
+	})
+}
+
+// tokenizeLyrics splits text into words, keeping where each one sits in
+// text. Terms are lower case without accents or apostrophes, so "Don't"
+// and "dont" are the same term.
+func tokenizeLyrics(text string) []lyricsToken {
+	var tokens []lyricsToken
+	var term strings.Builder
+	start := -1
+
+	flush := func(end int) {
+		if start >= 0 && term.Len() > 0 {
+			tokens = append(tokens, lyricsToken{term: RemoveDiacritics(term.String()), start: start, end: end})
+		}
+		term.Reset()
+		start = -1
+	}
+
+	for i, r := range text {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if start < 0 {
+				start = i
+			}
+			term.WriteRune(unicode.ToLower(r))
+		case (r == '\'' || r == '’') && start >= 0:
+		default:
+			flush(i)
+		}
+	}
+	flush(len(text))
+	return tokens
+}
+
+// phraseSpans returns the byte ranges of line covered by each occurrence
+// of query.
+func phraseSpans(line, query []lyricsToken) [][2]int {
+	var spans [][2]int
+	for i := 0; i+len(query) <= len(line); i++ {
+		found := true
+		for j := range query {
+			if line[i+j].term != query[j].term {
+				found = false
+				break
+			}
+		}
+		if found {
+			spans = append(spans, [2]int{line[i].start, line[i+len(query)-1].end})
+		}
+	}
+	return spans
+}
+
+func highlight(line string, spans [][2]int) []LineSegment {
+	var segments []LineSegment
+	pos := 0
+	for _, span := range spans {
+		if span[0] < pos {
+			span[0] = pos
+		}
+		if span[0] > pos {
+			segments = append(segments, LineSegment{Text: line[pos:span[0]]})
+		}
+		if span[1] > span[0] {
+			segments = append(segments, LineSegment{Text: line[span[0]:span[1]], Match: true})
+			pos = span[1]
+		}
+	}
+	if pos < len(line) {
+		segments = append(segments, LineSegment{Text: line[pos:]})
+	}
+	return segments
+}
+
+// containsAllTerms checks the postings before any line is scanned. The
+// caller must hold lyricsIndex.
+func containsAllTerms(songID string, query []lyricsToken) bool {
+	for _, token := range query {
+		if !lyricsIndex.postings[token.term][songID] {
+			return false
+		}
+	}
+	return true
+}
+
+// post and unpost keep the postings and term counts in step with docs.
+// The caller must hold lyricsIndex for writing.
+func post(doc *IndexedLyrics) {
+	lyricsIndex.vectors = nil
+	counts := termCounts(doc.Lines)
+	if len(counts) == 0 {
+		return
+	}
+	lyricsIndex.counts[doc.ID] = counts
+	for term := range counts {
+		ids := lyricsIndex.postings[term]
+		if ids == nil {
+			ids = make(map[string]bool)
+			lyricsIndex.postings[term] = ids
+		}
+		ids[doc.ID] = true
+	}
+}
+
+func unpost(songID string) {
+	lyricsIndex.vectors = nil
+	for term := range lyricsIndex.counts[songID] {
+		delete(lyricsIndex.postings[term], songID)
+		if len(lyricsIndex.postings[term]) == 0 {
+			delete(lyricsIndex.postings, term)
+		}
+	}
+	delete(lyricsIndex.counts, songID)
+}
+
+// termCounts counts how often each term occurs in lines.
+func termCounts(lines []string) map[string]int {
+	counts := make(map[string]int)
+	for _, line := range lines {
+		for _, token := range tokenizeLyrics(line) {
+			counts[token.term]++
+		}
+	}
+	return counts
+}
+
+func loadLyricsIndex() error {
+	lyricsIndex.Lock()
+	defer lyricsIndex.Unlock()
+
+	if lyricsIndex.loaded {
+		return nil
+	}
+	lyricsIndex.docs = make(map[string]*IndexedLyrics)
+	lyricsIndex.postings = make(map[string]map[string]bool)
+	lyricsIndex.counts = make(map[string]map[string]int)
+
+	data, err := ioutil.ReadFile(LyricsIndexFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		var docs []*IndexedLyrics
+		if err := json.Unmarshal(data, &docs); err != nil {
+			return err
+		}
+		for _, doc := range docs {
+			if doc.Language == "" && !doc.Missing {
+				doc.Language = DetectLanguage(strings.Join(doc.Lines, "\n"))
+			}
+			lyricsIndex.docs[doc.ID] = doc
+			post(doc)
+		}
+	}
+	lyricsIndex.loaded = true
+	return nil
+}
+
+// saveLyricsIndex writes the entries sorted by song ID. The caller must
+// hold lyricsIndex.
+func saveLyricsIndex() error {
+	docs := make([]*IndexedLyrics, 0, len(lyricsIndex.docs))
+	for _, doc := range lyricsIndex.docs {
+		docs = append(docs, doc)
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].ID < docs[j].ID })
+
+	if err := os.MkdirAll(filepath.Dir(LyricsIndexFile), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(docs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(LyricsIndexFile, data, 0644)
+}
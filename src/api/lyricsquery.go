@@ -0,0 +1,294 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LyricsQueriesFile remembers which query found the lyrics for a song, so
+// the next lookup for it tries that query first.
+var LyricsQueriesFile = "data/lyrics_queries.json"
+
+var lyricsOvhURL = "https://api.lyrics.ovh/v1"
+
+// MaxLyricsQueries caps how many candidates a single lookup tries.
+const MaxLyricsQueries = 6
+
+// LyricsLookupTimeout bounds a whole FetchLyrics call, however many
+// candidates it tries.
+const LyricsLookupTimeout = 8 * time.Second
+
+// LyricsQuery is one title/artist pair to try against the lyrics provider,
+// with a short description of how it was derived from the original.
+type LyricsQuery struct {
+	Title    string `json:"title"`
+	Artist   string `json:"artist"`
+	Strategy string `json:"strategy"`
+}
+
+type LyricsResult struct {
+	Lyrics string
+	Parsed ParsedLyrics
+	Query  LyricsQuery
+	Tried  int
+}
+
+var (
+	anyBracketPattern = regexp.MustCompile(`\s*[\(\[\{][^\)\]\}]*[\)\]\}]`)
+	creditSeparators  = regexp.MustCompile(`(?i)\s*(,|&|\bx\b|\band\b|\bfeat\.?|\bft\.?|\bfeaturing\b|\bwith\b)\s*`)
+
+	lyricsQueriesMu sync.Mutex
+)
+
+var diacritics = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ą': "a",
+	'æ': "ae", 'ç': "c", 'ć': "c", 'č': "c", 'ď': "d", 'đ': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ę': "e", 'ě': "e",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ī': "i", 'ł': "l",
+	'ñ': "n", 'ń': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ő': "o", 'œ': "oe",
+	'ř': "r", 'ś': "s", 'š': "s", 'ş': "s", 'ß': "ss", 'ť': "t", 'ţ': "t",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ū': "u", 'ů': "u", 'ű': "u",
+	'ý': "y", 'ÿ': "y", 'ź': "z", 'ż': "z", 'ž': "z",
+}
+
+// RemoveDiacritics replaces accented Latin letters with their plain
+// counterparts, e.g. "Beyoncé" becomes "Beyonce".
+func RemoveDiacritics(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		lower := []rune(strings.ToLower(string(r)))[0]
+		plain, ok := diacritics[lower]
+		switch {
+		case !ok:
+			b.WriteRune(r)
+		case lower != r:
+			b.WriteString(strings.ToUpper(plain[:1]) + plain[1:])
+		default:
+			b.WriteString(plain)
+		}
+	}
+	return b.String()
+}
+
+// CreditedArtists splits an artist credit like "Calvin Harris, Dua Lipa"
+// or "Silk Sonic & Bruno Mars" into the individual names. The full credit
+// comes first.
+func CreditedArtists(artist string) []string {
+	artist = strings.TrimSpace(artist)
+	names := []string{artist}
+	for _, name := range creditSeparators.Split(artist, -1) {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return uniqueStrings(names)
+}
+
+// PlanLyricsQueries lists the title/artist pairs to try for a song, most
+// specific first: the title as given, then with version and feat. clauses
+// removed, without any brackets, cut at " - ", and without diacritics, for
+// the full artist credit and then for each credited or featured artist on
+// their own.
+func PlanLyricsQueries(title, artist string) []LyricsQuery {
+	title = strings.TrimSpace(title)
+	info := ParseTitle(title)
+
+	type variant struct {
+		title, strategy string
+		raw             bool
+	}
+	var titles []variant
+	cleaned := false
+	addTitle := func(t, strategy string, raw bool) {
+		t = strings.TrimSpace(t)
+		for _, existing := range titles {
+			if t == "" || existing.title == t {
+				return
+			}
+		}
+		titles = append(titles, variant{t, strategy, raw})
+		cleaned = cleaned || !raw
+	}
+
+	addTitle(title, "original", true)
+	addTitle(info.Base, "version and feat. clauses removed", false)
+	addTitle(anyBracketPattern.ReplaceAllString(info.Base, ""), "brackets removed", false)
+	if i := strings.Index(title, " - "); i > 0 {
+		addTitle(anyBracketPattern.ReplaceAllString(title[:i], ""), "text after dash removed", false)
+	}
+	for _, t := range titles {
+		addTitle(RemoveDiacritics(t.title), t.strategy+", diacritics removed", t.raw)
+	}
+
+	artists := uniqueStrings(append(CreditedArtists(artist), info.Featuring...))
+	for _, a := range artists {
+		if folded := RemoveDiacritics(a); folded != a {
+			artists = append(artists, folded)
+		}
+	}
+
+	// Every title variant is tried with the full credit first. The other
+	// artists are only tried with the cleaned-up titles.
+	var queries []LyricsQuery
+	seen := make(map[string]bool)
+	for ai, a := range artists {
+		for _, t := range titles {
+			key := strings.ToLower(t.title + "|" + a)
+			if a == "" || seen[key] || (ai > 0 && t.raw && cleaned) {
+				continue
+			}
+			seen[key] = true
+
+			strategy := t.strategy
+			if ai > 0 {
+				strategy += fmt.Sprintf(", artist %q", a)
+			}
+			queries = append(queries, LyricsQuery{Title: t.title, Artist: a, Strategy: strategy})
+		}
+	}
+	return queries
+}
+
+// FetchLyrics looks up lyrics with the planned queries and records which
+// query worked. A query that worked before for the same song is tried on
+// its own first; otherwise the candidates are all sent at once, but the
+// earliest one in the plan that finds lyrics wins, so a looser query never
+// beats a closer one by answering sooner. The whole lookup gives up when
+// ctx is done or after LyricsLookupTimeout.
+func FetchLyrics(ctx context.Context, title, artist string) (LyricsResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, LyricsLookupTimeout)
+	defer cancel()
+
+	result := LyricsResult{}
+	found := func(q LyricsQuery, lyrics string) (LyricsResult, error) {
+		result.Lyrics, result.Parsed, result.Query = lyrics, ParseLyrics(lyrics), q
+		if err := rememberLyricsQuery(title, artist, q); err != nil {
+			log.Printf("Error recording lyrics query: %v", err)
+		}
+		return result, nil
+	}
+
+	known, ok := knownLyricsQuery(title, artist)
+	if ok {
+		result.Tried++
+		if lyrics, err := fetchLyricsOvh(ctx, known.Title, known.Artist); err == nil {
+			return found(known, lyrics)
+		}
+	}
+
+	var queries []LyricsQuery
+	for _, q := range PlanLyricsQueries(title, artist) {
+		if (!ok || q != known) && result.Tried+len(queries) < MaxLyricsQueries {
+			queries = append(queries, q)
+		}
+	}
+	result.Tried += len(queries)
+
+	type attempt struct {
+		index  int
+		lyrics string
+		err    error
+	}
+	attempts := make(chan attempt, len(queries))
+	for i, q := range queries {
+		go func(i int, q LyricsQuery) {
+			lyrics, err := fetchLyricsOvh(ctx, q.Title, q.Artist)
+			attempts <- attempt{i, lyrics, err}
+		}(i, q)
+	}
+
+	// Answers are kept until every earlier query has failed. Requests stop
+	// when ctx is done, so each query answers one way or another.
+	done := make([]*attempt, len(queries))
+	next := 0
+	lastErr := fmt.Errorf("no lyrics found")
+	for range queries {
+		a := <-attempts
+		done[a.index] = &a
+		for next < len(queries) && done[next] != nil {
+			if done[next].err == nil {
+				return found(queries[next], done[next].lyrics)
+			}
+			lastErr = done[next].err
+			next++
+		}
+	}
+	return result, lastErr
+}
+
+func lyricsQueryKey(title, artist string) string {
+	return NormalizeText(title) + "|" + NormalizeText(artist)
+}
+
+func knownLyricsQuery(title, artist string) (LyricsQuery, bool) {
+	lyricsQueriesMu.Lock()
+	defer lyricsQueriesMu.Unlock()
+
+	known, err := loadLyricsQueries()
+	if err != nil {
+		return LyricsQuery{}, false
+	}
+	q, ok := known[lyricsQueryKey(title, artist)]
+	return q, ok
+}
+
+func rememberLyricsQuery(title, artist string, q LyricsQuery) error {
+	lyricsQueriesMu.Lock()
+	defer lyricsQueriesMu.Unlock()
+
+	known, err := loadLyricsQueries()
+	if err != nil {
+		return err
+	}
+	key := lyricsQueryKey(title, artist)
+	if known[key] == q {
+		return nil
+	}
+	known[key] = q
+
+	if err := os.MkdirAll(filepath.Dir(LyricsQueriesFile), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(known, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(LyricsQueriesFile, data, 0644)
+}
+
+func loadLyricsQueries() (map[string]LyricsQuery, error) {
+	known := make(map[string]LyricsQuery)
+	data, err := ioutil.ReadFile(LyricsQueriesFile)
+	if os.IsNotExist(err) {
+		return known, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &known); err != nil {
+		return nil, err
+	}
+	return known, nil
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, v := range values {
+		if key := strings.ToLower(v); !seen[key] {
+			seen[key] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
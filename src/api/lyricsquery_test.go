@@ -0,0 +1,191 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPlanLyricsQueries(t *testing.T) {
+	tests := []struct {
+		title, artist string
+		want          []LyricsQuery
+	}{
+		{"Song", "A", []LyricsQuery{{"Song", "A", "original"}}},
+		{"Song (Live) - 2011 Remaster", "A", []LyricsQuery{
+			{"Song (Live) - 2011 Remaster", "A", "original"},
+			{"Song", "A", "version and feat. clauses removed"},
+		}},
+		{"Café", "A & B", []LyricsQuery{
+			{"Café", "A & B", "original"},
+			{"Cafe", "A & B", "original, diacritics removed"},
+			{"Café", "A", `original, artist "A"`},
+			{"Cafe", "A", `original, diacritics removed, artist "A"`},
+			{"Café", "B", `original, artist "B"`},
+			{"Cafe", "B", `original, diacritics removed, artist "B"`},
+		}},
+	}
+	for _, tt := range tests {
+		got := PlanLyricsQueries(tt.title, tt.artist)
+		if len(got) != len(tt.want) {
+			t.Errorf("PlanLyricsQueries(%q, %q) = %q, want %q", tt.title, tt.artist, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("PlanLyricsQueries(%q, %q)[%d] = %q, want %q", tt.title, tt.artist, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestFetchLyricsPrefersEarlierQueries(t *testing.T) {
+	tests := []struct {
+		name  string
+		title string
+		// responses maps a requested title to its delay and lyrics; an
+		// empty lyrics answers 404.
+		responses map[string]fakeLyrics
+		want      string
+		wantTitle string
+		wantErr   bool
+	}{
+		{
+			name:  "closer query answers later",
+			title: "Song (Live)",
+			responses: map[string]fakeLyrics{
+				"Song (Live)": {delay: 100 * time.Millisecond, lyrics: "live lyrics"},
+				"Song":        {lyrics: "studio lyrics"},
+			},
+			want:      "live lyrics",
+			wantTitle: "Song (Live)",
+		},
+		{
+			name:  "closer query fails later",
+			title: "Song (Live)",
+			responses: map[string]fakeLyrics{
+				"Song (Live)": {delay: 100 * time.Millisecond},
+				"Song":        {lyrics: "studio lyrics"},
+			},
+			want:      "studio lyrics",
+			wantTitle: "Song",
+		},
+		{
+			name:      "nothing found",
+			title:     "Song (Live)",
+			responses: map[string]fakeLyrics{},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeLyricsOvh(t, tt.responses)
+
+			result, err := FetchLyrics(context.Background(), tt.title, "A")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("FetchLyrics = %q, want an error", result.Lyrics)
+				}
+				if _, ok := knownLyricsQuery(tt.title, "A"); ok {
+					t.Error("a failed lookup was remembered")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Lyrics != tt.want || result.Query.Title != tt.wantTitle {
+				t.Errorf("FetchLyrics = %q from %q, want %q from %q", result.Lyrics, result.Query.Title, tt.want, tt.wantTitle)
+			}
+			if known, ok := knownLyricsQuery(tt.title, "A"); !ok || known.Title != tt.wantTitle {
+				t.Errorf("remembered query = %q, %v; want %q", known.Title, ok, tt.wantTitle)
+			}
+		})
+	}
+}
+
+func TestFetchLyricsKnownQueryFirst(t *testing.T) {
+	requests := useFakeLyricsOvh(t, map[string]fakeLyrics{
+		"Song (Live)": {lyrics: "live lyrics"},
+		"Song":        {lyrics: "studio lyrics"},
+	})
+	if err := rememberLyricsQuery("Song (Live)", "A", LyricsQuery{Title: "Song", Artist: "A"}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := FetchLyrics(context.Background(), "Song (Live)", "A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := requests(); result.Lyrics != "studio lyrics" || result.Tried != 1 || len(got) != 1 {
+		t.Errorf("FetchLyrics = %q after %d tries, requests %q; want the known query alone", result.Lyrics, result.Tried, got)
+	}
+}
+
+func TestFetchLyricsDeadline(t *testing.T) {
+	useFakeLyricsOvh(t, map[string]fakeLyrics{
+		"Song": {delay: time.Second, lyrics: "too late"},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := FetchLyrics(ctx, "Song", "A"); err == nil {
+		t.Error("FetchLyrics found lyrics after the deadline")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("FetchLyrics took %v, want it to stop at the deadline", elapsed)
+	}
+}
+
+type fakeLyrics struct {
+	delay  time.Duration
+	lyrics string
+}
+
+// useFakeLyricsOvh serves lyrics.ovh answers from responses, keyed by
+// title, and keeps remembered queries in a temporary file. It returns a
+// function listing the titles requested so far.
+func useFakeLyricsOvh(t *testing.T, responses map[string]fakeLyrics) func() []string {
+	t.Helper()
+	var mu sync.Mutex
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		title := path.Base(r.URL.Path)
+		mu.Lock()
+		requested = append(requested, title)
+		mu.Unlock()
+
+		response := responses[title]
+		select {
+		case <-time.After(response.delay):
+		case <-r.Context().Done():
+			return
+		}
+		if response.lyrics == "" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"lyrics": response.lyrics})
+	}))
+
+	url, queries := lyricsOvhURL, LyricsQueriesFile
+	lyricsOvhURL = server.URL
+	LyricsQueriesFile = filepath.Join(t.TempDir(), "lyrics_queries.json")
+	t.Cleanup(func() {
+		server.Close()
+		lyricsOvhURL, LyricsQueriesFile = url, queries
+	})
+
+	return func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), requested...)
+	}
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	_ "embed"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// moodLexicon is the word list the mood scorer is built on, bundled with
+// the binary.
+//
+//go:embed mood.lexicon
+var moodLexicon string
+
+// Moods are the mood tags a song can get, in display order.
+var Moods = []string{"happy", "sad", "angry", "fearful", "romantic"}
+
+const (
+	// negationWindow is how many words before a lexicon word a negation
+	// still applies to, within the same line.
+	negationWindow = 3
+	// minMoodHits is how often a mood must come up before a song is
+	// tagged with it.
+	minMoodHits = 2
+	// maxMoodTags caps how many mood tags a song gets.
+	maxMoodTags = 2
+)
+
+// Mood is the result of scoring a song's lyrics. Score runs from -1 (very
+// negative) to 1 (very positive); Sentiment is "positive", "negative" or
+// "neutral"; Tags are the strongest moods.
+type Mood struct {
+	Score     float64  `json:"score"`
+	Sentiment string   `json:"sentiment"`
+	Tags      []string `json:"tags,omitempty"`
+}
+
+type lexiconEntry struct {
+	valence int
+	moods   []string
+}
+
+var (
+	lexiconOnce sync.Once
+	lexicon     map[string]lexiconEntry
+
+	negations = map[string]bool{
+		"not": true, "no": true, "never": true, "nothing": true, "nobody": true,
+		"without": true, "neither": true, "nor": true, "hardly": true,
+		"dont": true, "doesnt": true, "didnt": true, "cant": true, "couldnt": true,
+		"wont": true, "wouldnt": true, "isnt": true, "arent": true, "wasnt": true,
+		"werent": true, "aint": true, "shouldnt": true,
+	}
+)
+
+// AnalyzeMood scores lyrics against the bundled lexicon. A negation shortly
+// before a word flips its valence and drops its moods, so "not happy"
+// counts as negative rather than happy. Repeated stanzas are left out, as
+// they are from the lines in the lyrics index, so a song gets the same
+// mood on its lyrics page as from IndexedMood.
+func AnalyzeMood(lyrics ParsedLyrics) Mood {
+	return moodOfLines(searchableLines(lyrics))
+}
+
+func moodOfLines(lines []string) Mood {
+	lexiconOnce.Do(loadLexicon)
+
+	sum, scored := 0, 0
+	hits := make(map[string]int)
+	for _, line := range lines {
+		tokens := tokenizeLyrics(line)
+		for i, token := range tokens {
+			entry, ok := lookupLexicon(token.term)
+			if !ok {
+				continue
+			}
+			scored++
+
+			negated := false
+			for j := i - 1; j >= 0 && j >= i-negationWindow; j-- {
+				if negations[tokens[j].term] {
+					negated = true
+					break
+				}
+			}
+			if negated {
+				sum -= entry.valence
+				continue
+			}
+			sum += entry.valence
+			for _, mood := range entry.moods {
+				hits[mood]++
+			}
+		}
+	}
+
+	// Averaging over the scored words, padded so a couple of words cannot
+	// swing a song to an extreme, keeps long and short songs comparable.
+	mood := Mood{Score: float64(sum) / float64(scored+5) / 3, Sentiment: "neutral"}
+	switch {
+	case mood.Score > 0.1:
+		mood.Sentiment = "positive"
+	case mood.Score < -0.1:
+		mood.Sentiment = "negative"
+	}
+
+	total := 0
+	for _, n := range hits {
+		total += n
+	}
+	for _, name := range Moods {
+		if hits[name] >= minMoodHits && hits[name]*4 >= total {
+			mood.Tags = append(mood.Tags, name)
+		}
+	}
+	sort.SliceStable(mood.Tags, func(i, j int) bool {
+		return hits[mood.Tags[i]] > hits[mood.Tags[j]]
+	})
+	if len(mood.Tags) > maxMoodTags {
+		mood.Tags = mood.Tags[:maxMoodTags]
+	}
+	return mood
+}
+
+// HasMood reports whether song matches a mood filter value, which is
+// either a mood tag or a sentiment.
+func (s Song) HasMood(mood string) bool {
+	if s.Sentiment == mood {
+		return true
+	}
+	for _, tag := range s.Moods {
+		if tag == mood {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupLexicon finds term in the lexicon, also trying it without a
+// plural, past tense or -ing ending.
+func lookupLexicon(term string) (lexiconEntry, bool) {
+	if entry, ok := lexicon[term]; ok {
+		return entry, true
+	}
+	for _, suffix := range []string{"s", "es", "ed", "d", "ing", "in"} {
+		stem := strings.TrimSuffix(term, suffix)
+		if stem == term || len(stem) < 3 {
+			continue
+		}
+		if entry, ok := lexicon[stem]; ok {
+			return entry, true
+		}
+		if entry, ok := lexicon[stem+"e"]; ok {
+			return entry, true
+		}
+	}
+	return lexiconEntry{}, false
+}
+
+func loadLexicon() {
+	lexicon = make(map[string]lexiconEntry)
+	for _, line := range strings.Split(moodLexicon, "\n") {
+		if strings.HasPrefix(line, ";;;") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		valence, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+		entry := lexiconEntry{valence: valence}
+		if len(fields) > 2 {
+			entry.moods = strings.Split(fields[2], ",")
+		}
+		lexicon[fields[0]] = entry
+	}
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnalyzeMood(t *testing.T) {
+	tests := []struct {
+		name      string
+		lyrics    string
+		sentiment string
+	}{
+		{"happy", "I am so happy today\nJoy and laughter everywhere", "positive"},
+		{"sad", "I am so sad and lonely\nCrying all night in pain", "negative"},
+		{"negated", "I am not happy\nI am never glad", "negative"},
+		{"no mood words", "The table by the window\nA chair beside the door", "neutral"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AnalyzeMood(ParseLyrics(tt.lyrics)); got.Sentiment != tt.sentiment {
+				t.Errorf("AnalyzeMood(%q) = %+v, want %s", tt.lyrics, got, tt.sentiment)
+			}
+		})
+	}
+}
+
+func TestAnalyzeMoodMatchesIndex(t *testing.T) {
+	useTempLyricsIndex(t)
+
+	// The chorus is sung three times; counting every time would tip the
+	// song towards sad.
+	lyrics := ParseLyrics("[Verse]\nSo happy, full of joy and love\nSmiling in the sunshine, glad\n\n" +
+		"[Chorus]\nSad and lonely\n\n[Chorus]\n\n[Chorus]")
+	song := Song{ID: "mood"}
+	if err := IndexLyrics(song, &lyrics); err != nil {
+		t.Fatal(err)
+	}
+
+	indexed, ok := IndexedMood(song.ID)
+	if !ok {
+		t.Fatal("IndexedMood found no mood")
+	}
+	if page := AnalyzeMood(lyrics); !reflect.DeepEqual(page, indexed) {
+		t.Errorf("AnalyzeMood = %+v, IndexedMood = %+v; want the same", page, indexed)
+	}
+	if stored, _ := IndexedLyricsOf(song.ID); !reflect.DeepEqual(AnalyzeMood(*stored), indexed) {
+		t.Errorf("mood of the stored lyrics = %+v, want %+v", AnalyzeMood(*stored), indexed)
+	}
+}
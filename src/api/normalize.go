@@ -0,0 +1,160 @@
+package api
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// TitleInfo is a track title split into the song itself and the clauses
+// that only describe a particular version or credit.
+type TitleInfo struct {
+	Base      string
+	Versions  []string
+	Featuring []string
+}
+
+// VersionGroup is a song together with other versions of it, such as
+// remasters, live recordings or edits.
+type VersionGroup struct {
+	Song
+	Others []Song
+}
+
+var (
+	// versionPattern matches a whole version clause, so "(Live at Wembley)"
+	// and "- Club Mix" are versions but "(Live Forever)" and "- Mix Tape"
+	// are part of the title.
+	versionPattern = regexp.MustCompile(`(?i)^(` +
+		`(\d{4}\s+)?(digital(ly)?\s+)?remaster(ed)?(\s+\d{4})?(\s+version)?` +
+		`|live(\s+(at|from|in|on)\s+.+|\s+\d{4}|\s+version|\s+recording)?` +
+		`|.*\b(re)?mix|.*\bedit|.*\bversion` +
+		`|mono|stereo|acoustic|unplugged|demo|instrumental|karaoke|re-?recorded|bonus\s+track` +
+		`)$`)
+	versionSeparators = regexp.MustCompile(`\s*[/;,]\s*`)
+	yearPattern       = regexp.MustCompile(`^\d{4}$`)
+	bracketPattern    = regexp.MustCompile(`\s*[\(\[]([^\)\]]*)[\)\]]`)
+	featPattern       = regexp.MustCompile(`(?i)^(feat\.?|ft\.?|featuring|with)\s+(.+)$`)
+	bareFeatPattern   = regexp.MustCompile(`(?i)\s+(feat\.|ft\.|featuring)\s+(.+)$`)
+	artistSeparators  = regexp.MustCompile(`(?i)\s*(,|\bfeat\.|\bft\.|\bfeaturing\b)\s*`)
+)
+
+// ParseTitle recognizes version clauses such as "- Remastered 2011",
+// "(Live)" or "[Radio Edit]" and feat. credits in a track title.
+// Bracketed parts that are not about the version, as in
+// "(I Can't Get No) Satisfaction", stay in the base title.
+func ParseTitle(title string) TitleInfo {
+	var info TitleInfo
+
+	base := bracketPattern.ReplaceAllStringFunc(title, func(match string) string {
+		inner := strings.TrimSpace(bracketPattern.FindStringSubmatch(match)[1])
+		if m := featPattern.FindStringSubmatch(inner); m != nil {
+			info.Featuring = append(info.Featuring, strings.TrimSpace(m[2]))
+			return ""
+		}
+		if isVersionClause(inner) {
+			info.Versions = append(info.Versions, inner)
+			return ""
+		}
+		return match
+	})
+
+	if i := strings.Index(base, " - "); i >= 0 {
+		suffix := strings.TrimSpace(base[i+3:])
+		if m := featPattern.FindStringSubmatch(suffix); m != nil {
+			info.Featuring = append(info.Featuring, strings.TrimSpace(m[2]))
+			base = base[:i]
+		} else if isVersionClause(suffix) {
+			info.Versions = append(info.Versions, suffix)
+			base = base[:i]
+		}
+	}
+
+	if m := bareFeatPattern.FindStringSubmatchIndex(base); m != nil {
+		info.Featuring = append(info.Featuring, strings.TrimSpace(base[m[4]:m[5]]))
+		base = base[:m[0]]
+	}
+
+	info.Base = strings.TrimSpace(base)
+	if info.Base == "" {
+		info.Base = strings.TrimSpace(title)
+	}
+	return info
+}
+
+// isVersionClause reports whether every part of clause, as in
+// "Live at Wembley / 2011 Remaster", describes a version. A year may
+// follow the first part, as in "Live at Wembley, 1986".
+func isVersionClause(clause string) bool {
+	if clause == "" {
+		return false
+	}
+	for i, part := range versionSeparators.Split(clause, -1) {
+		if !versionPattern.MatchString(part) && (i == 0 || !yearPattern.MatchString(part)) {
+			return false
+		}
+	}
+	return true
+}
+
+// VersionLabel describes which version of a song this is, for example
+// "Remastered 2011" or "Live, Radio Edit". It is empty for the plain
+// version.
+func (s Song) VersionLabel() string {
+	return strings.Join(ParseTitle(s.Title).Versions, ", ")
+}
+
+// VersionKey is shared by every version of the same song by the same
+// primary artist.
+func VersionKey(s Song) string {
+	return NormalizeText(ParseTitle(s.Title).Base) + "|" + NormalizeText(PrimaryArtist(s.Artist))
+}
+
+// PrimaryArtist returns the first credited artist.
+func PrimaryArtist(artist string) string {
+	return strings.TrimSpace(artistSeparators.Split(artist, 2)[0])
+}
+
+// OtherVersions returns the songs in songs that are a different version of
+// song, leaving out song itself.
+func OtherVersions(songs []Song, song Song) []Song {
+	key := VersionKey(song)
+	var others []Song
+	for _, s := range songs {
+		if !strings.EqualFold(s.ID, song.ID) && VersionKey(s) == key {
+			others = append(others, s)
+		}
+	}
+	return others
+}
+
+// GroupVersions folds versions of the same song into the first one that
+// appears, keeping the order of songs otherwise.
+func GroupVersions(songs []Song) []VersionGroup {
+	var groups []VersionGroup
+	index := make(map[string]int)
+	for _, song := range songs {
+		key := VersionKey(song)
+		if i, ok := index[key]; ok {
+			groups[i].Others = append(groups[i].Others, song)
+			continue
+		}
+		index[key] = len(groups)
+		groups = append(groups, VersionGroup{Song: song})
+	}
+	return groups
+}
+
+// NormalizeText lowercases s, drops punctuation and collapses whitespace.
+func NormalizeText(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		case unicode.IsSpace(r) || unicode.IsPunct(r):
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTitle(t *testing.T) {
+	tests := []struct {
+		title string
+		want  TitleInfo
+	}{
+		{"Yesterday", TitleInfo{Base: "Yesterday"}},
+		{"Yesterday - Remastered 2009", TitleInfo{Base: "Yesterday", Versions: []string{"Remastered 2009"}}},
+		{"Heroes - 2017 Remaster", TitleInfo{Base: "Heroes", Versions: []string{"2017 Remaster"}}},
+		{"Song (Digitally Remastered)", TitleInfo{Base: "Song", Versions: []string{"Digitally Remastered"}}},
+		{"Song (Live)", TitleInfo{Base: "Song", Versions: []string{"Live"}}},
+		{"Song - Live at Wembley, 1986", TitleInfo{Base: "Song", Versions: []string{"Live at Wembley, 1986"}}},
+		{"Song (Live at Wembley / 2011 Remaster)", TitleInfo{Base: "Song", Versions: []string{"Live at Wembley / 2011 Remaster"}}},
+		{"Song [Radio Edit]", TitleInfo{Base: "Song", Versions: []string{"Radio Edit"}}},
+		{"Song - Club Mix", TitleInfo{Base: "Song", Versions: []string{"Club Mix"}}},
+		{"Song (Acoustic) [Bonus Track]", TitleInfo{Base: "Song", Versions: []string{"Acoustic", "Bonus Track"}}},
+		{"Song (feat. Someone)", TitleInfo{Base: "Song", Featuring: []string{"Someone"}}},
+		{"Song - feat. Someone", TitleInfo{Base: "Song", Featuring: []string{"Someone"}}},
+		{"Song ft. A & B", TitleInfo{Base: "Song", Featuring: []string{"A & B"}}},
+		{"Song (with Someone) - Radio Edit", TitleInfo{Base: "Song", Versions: []string{"Radio Edit"}, Featuring: []string{"Someone"}}},
+
+		// Brackets and suffixes that are part of the title stay.
+		{"(I Can't Get No) Satisfaction", TitleInfo{Base: "(I Can't Get No) Satisfaction"}},
+		{"Live Forever", TitleInfo{Base: "Live Forever"}},
+		{"Song (Live Forever)", TitleInfo{Base: "Song (Live Forever)"}},
+		{"Song - Mix Tape", TitleInfo{Base: "Song - Mix Tape"}},
+		{"Song - Editorial", TitleInfo{Base: "Song - Editorial"}},
+		{"Song (1999)", TitleInfo{Base: "Song (1999)"}},
+		{"Live (Live)", TitleInfo{Base: "Live", Versions: []string{"Live"}}},
+		{"(Remastered)", TitleInfo{Base: "(Remastered)", Versions: []string{"Remastered"}}},
+	}
+	for _, tt := range tests {
+		if got := ParseTitle(tt.title); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseTitle(%q) = %+v, want %+v", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestVersionKey(t *testing.T) {
+	base := Song{Title: "Heroes", Artist: "David Bowie"}
+	tests := []struct {
+		song Song
+		same bool
+	}{
+		{Song{Title: "Heroes - 2017 Remaster", Artist: "David Bowie"}, true},
+		{Song{Title: "HEROES (Live)", Artist: "David Bowie, Someone"}, true},
+		{Song{Title: "Heroes", Artist: "David Bowie feat. Someone"}, true},
+		{Song{Title: "Heroes", Artist: "Someone Else"}, false},
+		{Song{Title: "Heroes (Live Forever)", Artist: "David Bowie"}, false},
+	}
+	for _, tt := range tests {
+		if same := VersionKey(tt.song) == VersionKey(base); same != tt.same {
+			t.Errorf("VersionKey(%q by %q) same as base = %v, want %v", tt.song.Title, tt.song.Artist, same, tt.same)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// PDF page size, A4 in points.
+const (
+	PDFPageWidth  = 595.28
+	PDFPageHeight = 841.89
+)
+
+// PDFFont is one of the standard Helvetica faces every PDF reader has, so
+// no font files need embedding.
+type PDFFont int
+
+const (
+	PDFRegular PDFFont = iota
+	PDFBold
+	PDFItalic
+)
+
+var pdfFontNames = []string{"Helvetica", "Helvetica-Bold", "Helvetica-Oblique"}
+
+// helveticaWidths and helveticaBoldWidths are the glyph widths of
+// characters 32 to 126, in thousandths of the font size. The oblique face
+// has the regular widths.
+var (
+	helveticaWidths = []int{
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	}
+	helveticaBoldWidths = []int{
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	}
+)
+
+// winAnsiExtras are the characters outside Latin-1 that WinAnsiEncoding
+// has a code for.
+var winAnsiExtras = map[rune]byte{
+	'€': 0x80, '…': 0x85, '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94,
+	'•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+}
+
+// PDFDocument builds a PDF of text pages. It only knows what a printed
+// songbook needs: text in the standard fonts, lines and internal links.
+type PDFDocument struct {
+	Title string
+	pages []*PDFPage
+}
+
+// PDFPage is a page of a PDFDocument. Coordinates are in points from the
+// bottom left corner, as in PDF itself.
+type PDFPage struct {
+	content bytes.Buffer
+	links   []pdfLink
+}
+
+type pdfLink struct {
+	x, y, width, height float64
+	page                int
+}
+
+// NewPDF starts an empty document.
+func NewPDF(title string) *PDFDocument {
+	return &PDFDocument{Title: title}
+}
+
+// AddPage appends a blank page and returns it.
+func (d *PDFDocument) AddPage() *PDFPage {
+	page := &PDFPage{}
+	d.pages = append(d.pages, page)
+	return page
+}
+
+// Pages returns how many pages the document has.
+func (d *PDFDocument) Pages() int {
+	return len(d.pages)
+}
+
+// Text writes text with its baseline starting at x, y, in gray from 0
+// (black) to 1 (white).
+func (p *PDFPage) Text(x, y float64, font PDFFont, size, gray float64, text string) {
+	fmt.Fprintf(&p.content, "BT %.2f g /F%d %.2f Tf %.2f %.2f Td (%s) Tj ET\n",
+		gray, int(font)+1, size, x, y, pdfString(text))
+}
+
+// Line draws a line of the given width and gray.
+func (p *PDFPage) Line(x1, y1, x2, y2, width, gray float64) {
+	fmt.Fprintf(&p.content, "%.2f G %.2f w %.2f %.2f m %.2f %.2f l S\n", gray, width, x1, y1, x2, y2)
+}
+
+// Link makes a rectangle of the page jump to another page, counted from 0,
+// when clicked.
+func (p *PDFPage) Link(x, y, width, height float64, page int) {
+	p.links = append(p.links, pdfLink{x: x, y: y, width: width, height: height, page: page})
+}
+
+// Bytes renders the document.
+func (d *PDFDocument) Bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	// Objects 1 to 6 are the catalog, the page tree, the info dictionary
+	// and the three fonts; each page then takes its page object, its
+	// content stream and its links.
+	pageObject := func(i int) int { return 7 + i*3 }
+
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", pageObject(i))
+	}
+
+	out.WriteString("%PDF-1.4\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d /MediaBox [0 0 %.2f %.2f] >>",
+		strings.Join(kids, " "), len(d.pages), PDFPageWidth, PDFPageHeight))
+	object(fmt.Sprintf("<< /Title (%s) /Producer (Harmonify) >>", pdfString(d.Title)))
+	for _, name := range pdfFontNames {
+		object(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", name))
+	}
+
+	for i, page := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 4 0 R /F2 5 0 R /F3 6 0 R >> >> /Contents %d 0 R /Annots %d 0 R >>",
+			pageObject(i)+1, pageObject(i)+2))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.content.Len(), page.content.String()))
+
+		annots := make([]string, 0, len(page.links))
+		for _, link := range page.links {
+			if link.page < 0 || link.page >= len(d.pages) {
+				continue
+			}
+			annots = append(annots, fmt.Sprintf("<< /Type /Annot /Subtype /Link /Rect [%.2f %.2f %.2f %.2f] /Border [0 0 0] /Dest [%d 0 R /Fit] >>",
+				link.x, link.y, link.x+link.width, link.y+link.height, pageObject(link.page)))
+		}
+		object("[" + strings.Join(annots, " ") + "]")
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info 3 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes()
+}
+
+// PDFTextWidth measures text in points as it would be written in font at
+// size. Characters without a known width count as wide as an "n".
+func PDFTextWidth(text string, font PDFFont, size float64) float64 {
+	widths := helveticaWidths
+	if font == PDFBold {
+		widths = helveticaBoldWidths
+	}
+	total := 0
+	for _, r := range text {
+		if r >= 32 && r <= 126 {
+			total += widths[r-32]
+		} else {
+			total += widths['n'-32]
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// pdfString encodes text in WinAnsiEncoding and escapes it for a PDF
+// string literal. Characters the encoding lacks become "?".
+func pdfString(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(byte(r))
+		case r >= 32 && r <= 126:
+			b.WriteByte(byte(r))
+		case r >= 0xA0 && r <= 0xFF:
+			fmt.Fprintf(&b, "\\%03o", r)
+		case winAnsiExtras[r] != 0:
+			fmt.Fprintf(&b, "\\%03o", winAnsiExtras[r])
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
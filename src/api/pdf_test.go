@@ -0,0 +1,41 @@
+package api
+
+import "testing"
+
+func TestPDFString(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Plain text", "Plain text"},
+		{`(a) \ b`, `\(a\) \\ b`},
+		{"café", `caf\351`},
+		{"Señor ½", `Se\361or \275`},
+		{"don’t “stop”", `don\222t \223stop\224`},
+		{"€5 – ok… •", `\2005 \226 ok\205 \225`},
+		{"™—", `\231\227`},
+		{"日本", "??"},
+		{"tab\there", "tab?here"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := pdfString(tt.text); got != tt.want {
+			t.Errorf("pdfString(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestPDFTextWidth(t *testing.T) {
+	if got := PDFTextWidth("", PDFRegular, 12); got != 0 {
+		t.Errorf("empty width = %v, want 0", got)
+	}
+	if regular, bold := PDFTextWidth("Hello", PDFRegular, 12), PDFTextWidth("Hello", PDFBold, 12); bold <= regular {
+		t.Errorf("bold width %v not wider than regular %v", bold, regular)
+	}
+	if small, big := PDFTextWidth("n", PDFRegular, 10), PDFTextWidth("n", PDFRegular, 20); big != 2*small {
+		t.Errorf("width does not scale with size: %v at 10, %v at 20", small, big)
+	}
+	if n, other := PDFTextWidth("n", PDFRegular, 10), PDFTextWidth("ñ", PDFRegular, 10); n != other {
+		t.Errorf("unknown character width = %v, want the width of n, %v", other, n)
+	}
+}
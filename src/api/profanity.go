@@ -0,0 +1,336 @@
+package api
+
+import (
+	_ "embed"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// profanityList is the word list profanity masking starts from, bundled
+// with the binary.
+//
+//go:embed profanity.list
+var profanityList string
+
+// leetLetters maps the digits and symbols typed in place of letters to the
+// letters they stand for. 1 and | can stand for i or l, so words with them
+// are tried both ways.
+var leetLetters = map[rune]rune{
+	'4': 'a', '@': 'a', '8': 'b', '3': 'e', '6': 'g', '1': 'i', '!': 'i',
+	'|': 'l', '0': 'o', '5': 's', '$': 's', '7': 't', '+': 't',
+}
+
+// leetEdges are leet symbols that are more often punctuation when they
+// start or end a word, as in "no way!".
+const leetEdges = "!|+"
+
+var (
+	profanityOnce    sync.Once
+	bundledProfanity profanityWords
+)
+
+// profanityWords is a set of words to mask, with the entries that end in
+// * kept apart as prefixes.
+type profanityWords struct {
+	words    map[string]bool
+	prefixes []string
+}
+
+func newProfanityWords(entries []string) profanityWords {
+	p := profanityWords{words: make(map[string]bool)}
+	for _, entry := range entries {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if prefix, ok := strings.CutSuffix(entry, "*"); ok {
+			if prefix != "" {
+				p.prefixes = append(p.prefixes, prefix)
+			}
+		} else if entry != "" {
+			p.words[entry] = true
+		}
+	}
+	return p
+}
+
+func (p profanityWords) match(word string) bool {
+	if p.words[word] {
+		return true
+	}
+	for _, prefix := range p.prefixes {
+		if strings.HasPrefix(word, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ProfanityMasker hides profane words, keeping their first letter and
+// replacing the rest with asterisks. It matches whole words against the
+// bundled list and the words added for one user, after undoing leetspeak
+// and stretched letters ("sh1t", "fuuuck").
+type ProfanityMasker struct {
+	extra profanityWords
+}
+
+// NewProfanityMasker returns a masker for the bundled word list plus
+// words, which follow the same format as the list: one word each, with a
+// trailing * to match every word starting with it.
+func NewProfanityMasker(words []string) *ProfanityMasker {
+	profanityOnce.Do(loadProfanityList)
+	return &ProfanityMasker{extra: newProfanityWords(words)}
+}
+
+// Mask returns text with its profane words masked, and how many it masked.
+func (m *ProfanityMasker) Mask(text string) (string, int) {
+	var b strings.Builder
+	masked := 0
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		if !isMaskableRune(runes[i]) {
+			b.WriteRune(runes[i])
+			i++
+			continue
+		}
+		end := i
+		for end < len(runes) && isMaskableRune(runes[end]) {
+			end++
+		}
+
+		start, stop := i, end
+		for start < stop && strings.ContainsRune(leetEdges, runes[start]) {
+			start++
+		}
+		for stop > start && strings.ContainsRune(leetEdges, runes[stop-1]) {
+			stop--
+		}
+		b.WriteString(string(runes[i:start]))
+		if word := runes[start:stop]; m.profane(string(word)) {
+			b.WriteRune(word[0])
+			b.WriteString(strings.Repeat("*", len(word)-1))
+			masked++
+		} else {
+			b.WriteString(string(word))
+		}
+		b.WriteString(string(runes[stop:end]))
+		i = end
+	}
+	return b.String(), masked
+}
+
+// MaskLyrics returns a copy of lyrics with the raw text and every stanza
+// masked, and how many words of the raw text it masked.
+func (m *ProfanityMasker) MaskLyrics(lyrics ParsedLyrics) (ParsedLyrics, int) {
+	raw, masked := m.Mask(lyrics.Raw)
+	result := ParsedLyrics{Raw: raw, Stanzas: make([]Stanza, len(lyrics.Stanzas))}
+	for i, stanza := range lyrics.Stanzas {
+		stanza.Label, _ = m.Mask(stanza.Label)
+		lines := make([]string, len(stanza.Lines))
+		for j, line := range stanza.Lines {
+			lines[j], _ = m.Mask(line)
+		}
+		stanza.Lines = lines
+		result.Stanzas[i] = stanza
+	}
+	return result, masked
+}
+
+// MaskTimed returns a copy of synced lyrics with every line masked.
+func (m *ProfanityMasker) MaskTimed(lyrics TimedLyrics) TimedLyrics {
+	lines := make([]TimedLine, len(lyrics.Lines))
+	for i, line := range lyrics.Lines {
+		line.Text, _ = m.Mask(line.Text)
+		lines[i] = line
+	}
+	lyrics.Lines = lines
+	return lyrics
+}
+
+// MaskAnalysis returns a copy of lyrics statistics with the most frequent
+// words masked. The statistics themselves should come from the lyrics
+// before masking, which turns a word like "f***" into "f".
+func (m *ProfanityMasker) MaskAnalysis(analysis LyricsAnalysis) LyricsAnalysis {
+	words := make([]WordCount, len(analysis.TopWords))
+	for i, word := range analysis.TopWords {
+		word.Word, _ = m.Mask(word.Word)
+		words[i] = word
+	}
+	analysis.TopWords = words
+	return analysis
+}
+
+// MaskRhymes returns a copy of a rhyme analysis with its lines and stanza
+// labels masked. Like MaskAnalysis, it is for rhymes found in the lyrics
+// before masking.
+func (m *ProfanityMasker) MaskRhymes(rhymes RhymeAnalysis) RhymeAnalysis {
+	stanzas := make([]RhymeStanza, len(rhymes.Stanzas))
+	for i, stanza := range rhymes.Stanzas {
+		stanza.Label, _ = m.Mask(stanza.Label)
+		lines := make([]RhymeLine, len(stanza.Lines))
+		for j, line := range stanza.Lines {
+			texts := make([]string, len(line.Segments))
+			for k, segment := range line.Segments {
+				texts[k] = segment.Text
+			}
+			texts = m.maskSegments(texts)
+			segments := make([]RhymeSegment, len(line.Segments))
+			for k, segment := range line.Segments {
+				segment.Text = texts[k]
+				segments[k] = segment
+			}
+			line.Segments = segments
+			lines[j] = line
+		}
+		stanza.Lines = lines
+		stanzas[i] = stanza
+	}
+	rhymes.Stanzas = stanzas
+	return rhymes
+}
+
+// MaskMatch returns a copy of a lyrics search match with its lines masked.
+// A matched phrase can split a word across segments, so each line is
+// masked as a whole.
+func (m *ProfanityMasker) MaskMatch(match LyricsMatch) LyricsMatch {
+	lines := make([][]LineSegment, len(match.Lines))
+	for i, line := range match.Lines {
+		texts := make([]string, len(line))
+		for j, segment := range line {
+			texts[j] = segment.Text
+		}
+		texts = m.maskSegments(texts)
+		segments := make([]LineSegment, len(line))
+		for j, segment := range line {
+			segment.Text = texts[j]
+			segments[j] = segment
+		}
+		lines[i] = segments
+	}
+	match.Lines = lines
+	return match
+}
+
+// MaskChordSheet returns a copy of a chord sheet with its title, section
+// labels and the lyrics between its chords masked. A chord can sit in the
+// middle of a word, so each line's lyrics are masked as a whole. Tab blocks
+// are left alone.
+func (m *ProfanityMasker) MaskChordSheet(sheet ChordSheet) ChordSheet {
+	sheet.Title, _ = m.Mask(sheet.Title)
+	sheet.Subtitle, _ = m.Mask(sheet.Subtitle)
+	sections := make([]ChordSection, len(sheet.Sections))
+	for i, section := range sheet.Sections {
+		section.Label, _ = m.Mask(section.Label)
+		lines := make([]ChordLine, len(section.Lines))
+		for j, line := range section.Lines {
+			line.Comment, _ = m.Mask(line.Comment)
+			if section.Kind != "tab" {
+				texts := make([]string, len(line.Segments))
+				for k, segment := range line.Segments {
+					texts[k] = segment.Text
+				}
+				texts = m.maskSegments(texts)
+				segments := make([]ChordSegment, len(line.Segments))
+				for k, segment := range line.Segments {
+					segment.Text = texts[k]
+					segments[k] = segment
+				}
+				line.Segments = segments
+			}
+			lines[j] = line
+		}
+		section.Lines = lines
+		sections[i] = section
+	}
+	sheet.Sections = sections
+	return sheet
+}
+
+// maskSegments masks texts that make up one line as if they were joined,
+// so a word split between two of them is still caught. Masking keeps the
+// number of runes, so the result is cut back at the same places.
+func (m *ProfanityMasker) maskSegments(texts []string) []string {
+	masked, _ := m.Mask(strings.Join(texts, ""))
+	runes := []rune(masked)
+	result := make([]string, len(texts))
+	for i, text := range texts {
+		n := utf8.RuneCountInString(text)
+		result[i] = string(runes[:n])
+		runes = runes[n:]
+	}
+	return result
+}
+
+// profane reports whether word is on either list, as written or with its
+// leetspeak undone, and also with runs of a repeated letter squeezed.
+func (m *ProfanityMasker) profane(word string) bool {
+	hasLetter := false
+	for _, r := range word {
+		if unicode.IsLetter(r) {
+			hasLetter = true
+			break
+		}
+	}
+	if !hasLetter {
+		return false
+	}
+
+	word = strings.ToLower(word)
+	for _, one := range []rune{'i', 'l'} {
+		plain := unleet(word, one)
+		for _, candidate := range []string{plain, squeeze(plain)} {
+			if bundledProfanity.match(candidate) || m.extra.match(candidate) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isMaskableRune reports whether r can be part of a word that gets masked.
+func isMaskableRune(r rune) bool {
+	_, leet := leetLetters[r]
+	return leet || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// unleet replaces leet symbols with the letters they stand for, reading 1
+// as one and | as the other of i and l.
+func unleet(word string, one rune) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '1':
+			return one
+		case '|':
+			if one == 'i' {
+				return 'l'
+			}
+			return 'i'
+		}
+		if letter, ok := leetLetters[r]; ok {
+			return letter
+		}
+		return r
+	}, word)
+}
+
+// squeeze collapses runs of the same letter into one.
+func squeeze(word string) string {
+	var b strings.Builder
+	var last rune
+	for i, r := range word {
+		if i == 0 || r != last {
+			b.WriteRune(r)
+		}
+		last = r
+	}
+	return b.String()
+}
+
+func loadProfanityList() {
+	var entries []string
+	for _, line := range strings.Split(profanityList, "\n") {
+		if !strings.HasPrefix(line, ";;;") {
+			entries = append(entries, line)
+		}
+	}
+	bundledProfanity = newProfanityWords(entries)
+}
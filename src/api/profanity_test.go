@@ -0,0 +1,109 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMask(t *testing.T) {
+	m := NewProfanityMasker([]string{"heck*"})
+	tests := []struct {
+		text   string
+		want   string
+		masked int
+	}{
+		{"oh shit now", "oh s*** now", 1},
+		{"Shit!", "S***!", 1},
+		{"sh1t and $hit", "s*** and $***", 2},
+		{"shiiiit", "s******", 1},
+		{"no way!", "no way!", 0},
+		{"heckin hecks", "h***** h****", 2},
+	}
+	for _, tt := range tests {
+		got, masked := m.Mask(tt.text)
+		if got != tt.want || masked != tt.masked {
+			t.Errorf("Mask(%q) = %q, %d; want %q, %d", tt.text, got, masked, tt.want, tt.masked)
+		}
+	}
+}
+
+func TestMaskChordSheet(t *testing.T) {
+	m := NewProfanityMasker(nil)
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"word in one segment", "[G]oh shit [C]now", []string{"oh s*** ", "now"}},
+		{"chord mid-word", "oh sh[Am]it now", []string{"oh s*", "** now"}},
+		{"chord at every letter", "[C]s[D]h[E]i[F]t", []string{"s", "*", "*", "*"}},
+		{"clean line", "[G]sun[D]shine", []string{"sun", "shine"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sheet, err := ParseChordPro(tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			masked := m.MaskChordSheet(sheet)
+			var got []string
+			for _, segment := range masked.Sections[0].Lines[0].Segments {
+				got = append(got, segment.Text)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("segments = %q, want %q", got, tt.want)
+			}
+			if masked.Chords() == nil || !reflect.DeepEqual(masked.Chords(), sheet.Chords()) {
+				t.Errorf("chords changed: %q, want %q", masked.Chords(), sheet.Chords())
+			}
+		})
+	}
+}
+
+func TestMaskChordSheetHeadings(t *testing.T) {
+	sheet, err := ParseChordPro("{title: Shit Song}\n{subtitle: more shit}\n{start_of_verse: Shit verse}\n[G]la\n{end_of_verse}\n{start_of_tab}\nshit|--0--|\n{end_of_tab}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	masked := NewProfanityMasker(nil).MaskChordSheet(sheet)
+	if masked.Title != "S*** Song" || masked.Subtitle != "more s***" {
+		t.Errorf("title = %q, subtitle = %q", masked.Title, masked.Subtitle)
+	}
+	if label := masked.Sections[0].Label; label != "S*** verse" {
+		t.Errorf("label = %q", label)
+	}
+	if tab := masked.Sections[1].Lines[0].Segments[0].Text; tab != "shit|--0--|" {
+		t.Errorf("tab = %q, want it left alone", tab)
+	}
+	if sheet.Title != "Shit Song" {
+		t.Errorf("original title changed to %q", sheet.Title)
+	}
+}
+
+func TestMaskAnalysisAndRhymes(t *testing.T) {
+	m := NewProfanityMasker(nil)
+	lyrics := ParseLyrics("[Verse]\nWhat a load of shit\nI am so over it\nThis shit is a hit\nShit shit shit")
+
+	analysis := m.MaskAnalysis(AnalyzeLyrics(lyrics))
+	if len(analysis.TopWords) == 0 || analysis.TopWords[0] != (WordCount{"s***", 5}) {
+		t.Errorf("top words = %+v, want s*** counted 5 times first", analysis.TopWords)
+	}
+	if plain := AnalyzeLyrics(lyrics); plain.TopWords[0].Word != "shit" {
+		t.Errorf("MaskAnalysis changed the analysis it was given: %+v", plain.TopWords)
+	}
+
+	rhymes := m.MaskRhymes(AnalyzeRhymes(lyrics))
+	if len(rhymes.Stanzas) != 1 || len(rhymes.Stanzas[0].Lines) != 4 {
+		t.Fatalf("rhymes = %+v, want one stanza of four lines", rhymes)
+	}
+	if got := rhymes.Stanzas[0].Scheme; got != AnalyzeRhymes(lyrics).Stanzas[0].Scheme {
+		t.Errorf("scheme = %q, want the scheme of the real words", got)
+	}
+	var line string
+	for _, segment := range rhymes.Stanzas[0].Lines[0].Segments {
+		line += segment.Text
+	}
+	if line != "What a load of s***" {
+		t.Errorf("first line = %q, want it masked", line)
+	}
+}
@@ -0,0 +1,306 @@
+package api
+
+import (
+	_ "embed"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// rhymesDict is a small pronunciation dictionary bundled with the binary,
+// in CMU Pronouncing Dictionary format.
+//
+//go:embed rhymes.dict
+var rhymesDict string
+
+// rhymeColors is how many colours the lyrics page cycles through for
+// rhyme groups.
+const rhymeColors = 6
+
+// RhymeSegment is a piece of a lyrics line. End marks the line's last
+// word; words with the same non-zero Internal number rhyme with each
+// other inside the line.
+type RhymeSegment struct {
+	Text     string
+	End      bool
+	Internal int
+}
+
+// RhymeLine is a line with the letter of its end rhyme, as in "ABAB".
+type RhymeLine struct {
+	Letter   string
+	Segments []RhymeSegment
+}
+
+// RhymeStanza is the rhyme scheme of one stanza.
+type RhymeStanza struct {
+	Label  string
+	Scheme string
+	Lines  []RhymeLine
+}
+
+// RhymeAnalysis is the rhyme structure of a song. Scheme is the stanza
+// scheme that occurs most often, the longest one on a tie, and Name
+// describes it when it has a common name.
+type RhymeAnalysis struct {
+	Stanzas []RhymeStanza
+	Scheme  string
+	Name    string
+}
+
+var schemeNames = map[string]string{
+	"AA":   "couplet",
+	"AABB": "couplets",
+	"ABAB": "alternate rhyme",
+	"ABCB": "ballad stanza",
+	"ABBA": "enclosed rhyme",
+	"AAAA": "monorhyme",
+	"AABA": "rubaiyat",
+}
+
+var (
+	pronunciationsOnce sync.Once
+	pronunciations     map[string]string
+)
+
+// AnalyzeRhymes labels the end rhyme of every line, stanza by stanza, and
+// marks internal rhymes. Repeated stanzas are left out.
+func AnalyzeRhymes(lyrics ParsedLyrics) RhymeAnalysis {
+	var analysis RhymeAnalysis
+	counts := make(map[string]int)
+
+	for _, stanza := range lyrics.Stanzas {
+		if stanza.Repeat {
+			continue
+		}
+		rhymed := RhymeStanza{Label: stanza.Label}
+
+		var ends []string
+		var letters []string
+		next := 0
+		for _, line := range stanza.Lines {
+			tokens := tokenizeLyrics(line)
+			letter := ""
+			if len(tokens) > 0 {
+				end := tokens[len(tokens)-1].term
+				for i, previous := range ends {
+					if previous != "" && Rhymes(previous, end) {
+						letter = letters[i]
+						break
+					}
+				}
+				if letter == "" {
+					letter = rhymeLetter(next)
+					next++
+				}
+				ends = append(ends, end)
+			} else {
+				ends = append(ends, "")
+			}
+			letters = append(letters, letter)
+
+			rhymed.Lines = append(rhymed.Lines, RhymeLine{Letter: letter, Segments: rhymeSegments(line, tokens)})
+			rhymed.Scheme += letter
+		}
+
+		if len(rhymed.Lines) > 1 {
+			counts[rhymed.Scheme]++
+		}
+		analysis.Stanzas = append(analysis.Stanzas, rhymed)
+	}
+
+	schemes := make([]string, 0, len(counts))
+	for scheme := range counts {
+		schemes = append(schemes, scheme)
+	}
+	sort.Slice(schemes, func(i, j int) bool {
+		if counts[schemes[i]] != counts[schemes[j]] {
+			return counts[schemes[i]] > counts[schemes[j]]
+		}
+		if len(schemes[i]) != len(schemes[j]) {
+			return len(schemes[i]) > len(schemes[j])
+		}
+		return schemes[i] < schemes[j]
+	})
+	if len(schemes) > 0 {
+		analysis.Scheme = schemes[0]
+		analysis.Name = schemeNames[analysis.Scheme]
+	}
+	return analysis
+}
+
+// Color numbers the line's rhyme letter for styling, from 1 to
+// rhymeColors.
+func (l RhymeLine) Color() int {
+	if l.Letter == "" {
+		return 0
+	}
+	return int(l.Letter[0]-'A')%rhymeColors + 1
+}
+
+// Rhymes reports whether two words rhyme. Words in the bundled dictionary
+// are compared by sound from their last stressed vowel on; other words by
+// spelling. A word rhymes with itself.
+func Rhymes(a, b string) bool {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	if a == b {
+		return a != ""
+	}
+	soundA, okA := phoneticRhyme(a)
+	soundB, okB := phoneticRhyme(b)
+	if okA && okB {
+		return soundA == soundB
+	}
+	spellingA, spellingB := spellingRhyme(a), spellingRhyme(b)
+	return spellingA != "" && spellingA == spellingB
+}
+
+// rhymeSegments splits line into its words and the text between them,
+// marking the end word and the words that rhyme with each other.
+func rhymeSegments(line string, tokens []lyricsToken) []RhymeSegment {
+	groups := internalRhymes(tokens)
+
+	var segments []RhymeSegment
+	pos := 0
+	for i, token := range tokens {
+		if token.start > pos {
+			segments = append(segments, RhymeSegment{Text: line[pos:token.start]})
+		}
+		segments = append(segments, RhymeSegment{
+			Text:     line[token.start:token.end],
+			End:      i == len(tokens)-1,
+			Internal: groups[i],
+		})
+		pos = token.end
+	}
+	if pos < len(line) {
+		segments = append(segments, RhymeSegment{Text: line[pos:]})
+	}
+	return segments
+}
+
+// internalRhymes numbers the groups of different words in one line that
+// rhyme, by token index. Short words and stopwords only take part as the
+// line's last word.
+func internalRhymes(tokens []lyricsToken) map[int]int {
+	var candidates []int
+	for i, token := range tokens {
+		last := i == len(tokens)-1
+		if last || (len([]rune(token.term)) >= 3 && !stopwords[token.term]) {
+			candidates = append(candidates, i)
+		}
+	}
+
+	groups := make(map[int]int)
+	next := 1
+	for x, i := range candidates {
+		for _, j := range candidates[x+1:] {
+			if tokens[i].term == tokens[j].term || !Rhymes(tokens[i].term, tokens[j].term) {
+				continue
+			}
+			if groups[i] == 0 {
+				groups[i] = next
+				next++
+			}
+			if groups[j] == 0 {
+				groups[j] = groups[i]
+			}
+		}
+	}
+	return groups
+}
+
+// phoneticRhyme returns the sounds of word from its last stressed vowel
+// to the end, without stress marks.
+func phoneticRhyme(word string) (string, bool) {
+	pronunciationsOnce.Do(loadPronunciations)
+	phones, ok := pronunciations[strings.ToUpper(word)]
+	if !ok {
+		return "", false
+	}
+
+	fields := strings.Fields(phones)
+	start := -1
+	for i, phone := range fields {
+		if strings.HasSuffix(phone, "1") || strings.HasSuffix(phone, "2") {
+			start = i
+		}
+	}
+	if start < 0 {
+		for i, phone := range fields {
+			if strings.HasSuffix(phone, "0") {
+				start = i
+			}
+		}
+	}
+	if start < 0 {
+		return "", false
+	}
+
+	rhyme := fields[start:]
+	for i, phone := range rhyme {
+		rhyme[i] = strings.TrimRight(phone, "012")
+	}
+	return strings.Join(rhyme, " "), true
+}
+
+// spellingRhyme guesses the rhyming part of a word from its spelling: the
+// last vowel group and what follows, keeping a silent final e and reading
+// y as i, so "time" and "rhyme" match.
+func spellingRhyme(word string) string {
+	letters := []rune(word)
+	silentE := len(letters) > 2 && letters[len(letters)-1] == 'e' && !isRhymeVowel(letters, len(letters)-2)
+	if silentE {
+		letters = letters[:len(letters)-1]
+	}
+
+	i := len(letters) - 1
+	for i >= 0 && !isRhymeVowel(letters, i) {
+		i--
+	}
+	if i < 0 {
+		return ""
+	}
+	for i > 0 && isRhymeVowel(letters, i-1) {
+		i--
+	}
+
+	rhyme := strings.ReplaceAll(string(letters[i:]), "y", "i")
+	if silentE {
+		rhyme += "e"
+	}
+	return rhyme
+}
+
+func isRhymeVowel(letters []rune, i int) bool {
+	switch unicode.ToLower(letters[i]) {
+	case 'a', 'e', 'i', 'o', 'u':
+		return true
+	case 'y':
+		return i > 0
+	}
+	return false
+}
+
+func rhymeLetter(n int) string {
+	letter := string(rune('A' + n%26))
+	if n >= 26 {
+		letter += strconv.Itoa(n / 26)
+	}
+	return letter
+}
+
+func loadPronunciations() {
+	pronunciations = make(map[string]string)
+	for _, line := range strings.Split(rhymesDict, "\n") {
+		if line == "" || strings.HasPrefix(line, ";;;") {
+			continue
+		}
+		word, phones, ok := strings.Cut(line, "  ")
+		if ok {
+			pronunciations[word] = strings.TrimSpace(phones)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	// MaxSimilarSongs caps how many similar songs are listed.
+	MaxSimilarSongs = 5
+	// MaxSharedTerms caps how many shared words are given as the reason a
+	// song is similar.
+	MaxSharedTerms = 5
+	// MinSimilarity is the cosine similarity below which songs are not
+	// considered similar at all.
+	MinSimilarity = 0.05
+)
+
+// SimilarSong is a song whose lyrics resemble another song's. Score is the
+// cosine similarity of their TF-IDF vectors, from 0 to 1, and Terms are
+// the shared words that weigh the most in it. The song only carries the
+// ID, title and artist it was indexed with.
+type SimilarSong struct {
+	Song  Song
+	Score float64
+	Terms []string
+}
+
+// Percent is Score as a whole percentage.
+func (s SimilarSong) Percent() int {
+	return int(s.Score*100 + 0.5)
+}
+
+// SimilarLyrics compares lyrics with every song in the lyrics index and
+// returns the most similar ones, best first. Words are weighted by TF-IDF
+// over the index, so words that many songs use count for little. The song
+// itself, and other versions of it with the same VersionKey, such as
+// remasters or live recordings, are left out.
+func SimilarLyrics(song Song, lyrics ParsedLyrics) []SimilarSong {
+	if err := loadLyricsIndex(); err != nil {
+		return nil
+	}
+	lyricsIndex.RLock()
+	defer lyricsIndex.RUnlock()
+
+	total := len(lyricsIndex.counts)
+	vector := tfidf(termCounts(searchableLines(lyrics)), total)
+	if len(vector) == 0 {
+		return nil
+	}
+	key := VersionKey(song)
+	vectors := indexVectors()
+
+	var similar []SimilarSong
+	for id, other := range vectors {
+		doc := lyricsIndex.docs[id]
+		if id == song.ID || VersionKey(Song{Title: doc.Title, Artist: doc.Artist}) == key {
+			continue
+		}
+		score, terms := cosine(vector, other)
+		if score < MinSimilarity {
+			continue
+		}
+		similar = append(similar, SimilarSong{
+			Song:  Song{ID: doc.ID, Title: doc.Title, Artist: doc.Artist},
+			Score: score,
+			Terms: terms,
+		})
+	}
+
+	sort.Slice(similar, func(i, j int) bool {
+		if similar[i].Score != similar[j].Score {
+			return similar[i].Score > similar[j].Score
+		}
+		return similar[i].Song.ID < similar[j].Song.ID
+	})
+	if len(similar) > MaxSimilarSongs {
+		similar = similar[:MaxSimilarSongs]
+	}
+	return similar
+}
+
+// indexVectors returns the TF-IDF vector of every song in the index,
+// working them out again only after the index has changed. The caller
+// must hold lyricsIndex for reading.
+func indexVectors() map[string]map[string]float64 {
+	lyricsIndex.vectorsMu.Lock()
+	defer lyricsIndex.vectorsMu.Unlock()
+
+	if lyricsIndex.vectors == nil {
+		total := len(lyricsIndex.counts)
+		vectors := make(map[string]map[string]float64, total)
+		for id, counts := range lyricsIndex.counts {
+			vectors[id] = tfidf(counts, total)
+		}
+		lyricsIndex.vectors = vectors
+	}
+	return lyricsIndex.vectors
+}
+
+// tfidf weights term counts with a log-scaled term frequency and a
+// smoothed inverse document frequency over total indexed songs. Stopwords
+// and single letters are left out. The caller must hold lyricsIndex.
+func tfidf(counts map[string]int, total int) map[string]float64 {
+	vector := make(map[string]float64)
+	for term, count := range counts {
+		if stopwords[term] || len([]rune(term)) < 2 {
+			continue
+		}
+		df := len(lyricsIndex.postings[term])
+		idf := math.Log(float64(total+1)/float64(df+1)) + 1
+		vector[term] = (1 + math.Log(float64(count))) * idf
+	}
+	return vector
+}
+
+// cosine returns the cosine similarity of two vectors and the shared terms
+// that contribute the most to it.
+func cosine(a, b map[string]float64) (float64, []string) {
+	var dot, normA, normB float64
+	contributions := make(map[string]float64)
+	for term, weight := range a {
+		normA += weight * weight
+		if other, ok := b[term]; ok {
+			contributions[term] = weight * other
+			dot += weight * other
+		}
+	}
+	for _, weight := range b {
+		normB += weight * weight
+	}
+	if dot == 0 {
+		return 0, nil
+	}
+
+	terms := make([]string, 0, len(contributions))
+	for term := range contributions {
+		terms = append(terms, term)
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if contributions[terms[i]] != contributions[terms[j]] {
+			return contributions[terms[i]] > contributions[terms[j]]
+		}
+		return terms[i] < terms[j]
+	})
+	if len(terms) > MaxSharedTerms {
+		terms = terms[:MaxSharedTerms]
+	}
+	return dot / math.Sqrt(normA*normB), terms
+}
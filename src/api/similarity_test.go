@@ -0,0 +1,60 @@
+package api
+
+import "testing"
+
+func TestSimilarLyrics(t *testing.T) {
+	useTempLyricsIndex(t)
+
+	original := Song{ID: "orig", Title: "Ocean Eyes", Artist: "Singer"}
+	oceanLyrics := "Ocean waves and sailing ships\nThe harbour lights at midnight\nSailing home across the ocean"
+	index := func(song Song, text string) {
+		t.Helper()
+		lyrics := ParseLyrics(text)
+		if err := IndexLyrics(song, &lyrics); err != nil {
+			t.Fatal(err)
+		}
+	}
+	index(original, oceanLyrics)
+	index(Song{ID: "remaster", Title: "Ocean Eyes - 2015 Remaster", Artist: "Singer"}, oceanLyrics)
+	index(Song{ID: "live", Title: "Ocean Eyes (Live at Wembley)", Artist: "Singer, Guest"}, oceanLyrics)
+	index(Song{ID: "cover", Title: "Ocean Eyes", Artist: "Someone Else"}, oceanLyrics)
+	index(Song{ID: "sea", Title: "Sea Song", Artist: "Other"}, "Sailing ships upon the ocean\nWaves that crash at midnight")
+	index(Song{ID: "city", Title: "City Song", Artist: "Other"}, "Traffic jams and concrete towers\nNeon signs on every corner")
+
+	ids := func(similar []SimilarSong) map[string]bool {
+		found := make(map[string]bool)
+		for _, s := range similar {
+			found[s.Song.ID] = true
+		}
+		return found
+	}
+
+	similar := SimilarLyrics(original, ParseLyrics(oceanLyrics))
+	found := ids(similar)
+	for _, id := range []string{"orig", "remaster", "live"} {
+		if found[id] {
+			t.Errorf("%s is a version of the song and should be left out", id)
+		}
+	}
+	if !found["cover"] || !found["sea"] {
+		t.Errorf("similar = %+v, want the cover and the sea song", similar)
+	}
+	if found["city"] {
+		t.Error("city song shares no words and should be left out")
+	}
+	if len(similar) > 0 && similar[0].Song.ID != "cover" {
+		t.Errorf("best match = %s, want the cover with the same lyrics", similar[0].Song.ID)
+	}
+
+	// A later write to the index must be seen, not the cached vectors.
+	index(Song{ID: "late", Title: "Late Song", Artist: "New"}, oceanLyrics)
+	if !ids(SimilarLyrics(original, ParseLyrics(oceanLyrics)))["late"] {
+		t.Error("song indexed after the first lookup is missing")
+	}
+	if err := UnindexLyrics("cover"); err != nil {
+		t.Fatal(err)
+	}
+	if ids(SimilarLyrics(original, ParseLyrics(oceanLyrics)))["cover"] {
+		t.Error("song dropped from the index is still listed")
+	}
+}
@@ -0,0 +1,290 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Songbook page layout, in points.
+const (
+	songbookMargin    = 56
+	songbookTop       = PDFPageHeight - 64
+	songbookBottom    = 64
+	songbookFooter    = 36
+	songbookLyricSize = 11
+	songbookLeading   = 15
+	songbookTOCRow    = 18
+)
+
+// SongbookSong is a song as printed in a songbook. Lyrics is nil when none
+// were found.
+type SongbookSong struct {
+	Song   Song
+	Lyrics *ParsedLyrics
+}
+
+// Songbook is a printable collection of songs with their lyrics.
+type Songbook struct {
+	Title   string
+	Songs   []SongbookSong
+	Created time.Time
+}
+
+// songbookPage is the drawing of one page, kept until page numbers are
+// known.
+type songbookPage []func(*PDFPage)
+
+// Filename is "Title songbook.pdf" with characters that are unsafe in file
+// names removed.
+func (b Songbook) Filename() string {
+	name := strings.TrimSpace(unsafeFilename.ReplaceAllString(b.Title, ""))
+	if name == "" {
+		return "songbook.pdf"
+	}
+	return name + " songbook.pdf"
+}
+
+// PDF lays the songbook out: a cover, a table of contents that links to
+// every song, then each song starting on a new page and running onto more
+// pages when its lyrics are long. Every page but the cover is numbered.
+func (b Songbook) PDF() []byte {
+	songs := make([][]songbookPage, len(b.Songs))
+	for i, song := range b.Songs {
+		songs[i] = layoutSongbookSong(song)
+	}
+
+	perTOCPage := int(math.Floor((songbookTop - 40 - songbookBottom) / songbookTOCRow))
+	tocPages := (len(b.Songs) + perTOCPage - 1) / perTOCPage
+	if tocPages == 0 {
+		tocPages = 1
+	}
+	starts := make([]int, len(b.Songs))
+	next := 1 + tocPages
+	for i := range songs {
+		starts[i] = next
+		next += len(songs[i])
+	}
+
+	doc := NewPDF(b.Title)
+	b.drawCover(doc.AddPage())
+	for i := 0; i < tocPages; i++ {
+		from := i * perTOCPage
+		to := from + perTOCPage
+		if to > len(b.Songs) {
+			to = len(b.Songs)
+		}
+		b.drawContents(doc.AddPage(), i == 0, from, to, starts)
+	}
+	for _, pages := range songs {
+		for _, draw := range pages {
+			page := doc.AddPage()
+			for _, op := range draw {
+				op(page)
+			}
+		}
+	}
+
+	for i, page := range doc.pages[1:] {
+		number := fmt.Sprint(i + 2)
+		page.Text((PDFPageWidth-PDFTextWidth(number, PDFRegular, 9))/2, songbookFooter, PDFRegular, 9, 0.45, number)
+	}
+	return doc.Bytes()
+}
+
+func (b Songbook) drawCover(page *PDFPage) {
+	y := PDFPageHeight * 0.6
+	page.Line(songbookMargin, y+40, PDFPageWidth-songbookMargin, y+40, 1.5, 0)
+	for _, line := range wrapPDFText(b.Title, PDFBold, 30, PDFPageWidth-2*songbookMargin) {
+		page.Text((PDFPageWidth-PDFTextWidth(line, PDFBold, 30))/2, y, PDFBold, 30, 0, line)
+		y -= 36
+	}
+
+	count := fmt.Sprintf("%d songs", len(b.Songs))
+	if len(b.Songs) == 1 {
+		count = "1 song"
+	}
+	page.Text((PDFPageWidth-PDFTextWidth(count, PDFRegular, 14))/2, y-6, PDFRegular, 14, 0.35, count)
+	if !b.Created.IsZero() {
+		created := "Compiled on " + b.Created.Format("2 January 2006")
+		page.Text((PDFPageWidth-PDFTextWidth(created, PDFItalic, 11))/2, y-28, PDFItalic, 11, 0.45, created)
+	}
+
+	footer := "Made with Harmonify"
+	page.Text((PDFPageWidth-PDFTextWidth(footer, PDFRegular, 9))/2, songbookMargin, PDFRegular, 9, 0.45, footer)
+}
+
+// drawContents writes the contents entries from up to to, with dot leaders
+// to the page each song starts on.
+func (b Songbook) drawContents(page *PDFPage, first bool, from, to int, starts []int) {
+	y := float64(songbookTop)
+	heading := "Contents"
+	if !first {
+		heading = "Contents (continued)"
+	}
+	page.Text(songbookMargin, y, PDFBold, 20, 0, heading)
+	y -= 40
+
+	right := PDFPageWidth - songbookMargin
+	for i := from; i < to; i++ {
+		song := b.Songs[i].Song
+		number := fmt.Sprint(starts[i] + 1)
+		numberX := right - PDFTextWidth(number, PDFRegular, songbookLyricSize)
+
+		title := fmt.Sprintf("%d. %s", i+1, song.Title)
+		room := numberX - songbookMargin - 24
+		title = truncatePDFText(title, PDFRegular, songbookLyricSize, room)
+		page.Text(songbookMargin, y, PDFRegular, songbookLyricSize, 0, title)
+		x := songbookMargin + PDFTextWidth(title, PDFRegular, songbookLyricSize)
+
+		if artist := truncatePDFText(" – "+song.Artist, PDFItalic, songbookLyricSize, songbookMargin+room-x); artist != "" {
+			page.Text(x, y, PDFItalic, songbookLyricSize, 0.4, artist)
+			x += PDFTextWidth(artist, PDFItalic, songbookLyricSize)
+		}
+
+		dot := PDFTextWidth(".", PDFRegular, songbookLyricSize)
+		if dots := int((numberX - x - 12) / dot); dots > 0 {
+			page.Text(numberX-6-float64(dots)*dot, y, PDFRegular, songbookLyricSize, 0.6, strings.Repeat(".", dots))
+		}
+		page.Text(numberX, y, PDFRegular, songbookLyricSize, 0, number)
+		page.Link(songbookMargin, y-4, right-songbookMargin, songbookTOCRow-2, starts[i])
+		y -= songbookTOCRow
+	}
+}
+
+// layoutSongbookSong draws a song onto as many pages as its lyrics need.
+// Stanzas are kept on one page when they fit on one, and repeated
+// stanzas are printed as a reference to save room.
+func layoutSongbookSong(s SongbookSong) []songbookPage {
+	song := s.Song
+	var pages []songbookPage
+	var current songbookPage
+	y := float64(songbookTop)
+	width := PDFPageWidth - 2*songbookMargin
+
+	add := func(op func(*PDFPage)) {
+		current = append(current, op)
+	}
+	text := func(x, y float64, font PDFFont, size, gray float64, line string) {
+		add(func(p *PDFPage) { p.Text(x, y, font, size, gray, line) })
+	}
+	rule := func(y float64) {
+		add(func(p *PDFPage) { p.Line(songbookMargin, y, PDFPageWidth-songbookMargin, y, 0.75, 0.6) })
+	}
+	newPage := func() {
+		pages = append(pages, current)
+		current = nil
+		y = songbookTop
+		text(songbookMargin, y, PDFBold, songbookLyricSize, 0.4, truncatePDFText(song.Title+" (continued)", PDFBold, songbookLyricSize, width))
+		y -= 10
+		rule(y)
+		y -= 24
+	}
+
+	for _, line := range wrapPDFText(song.Title, PDFBold, 20, width) {
+		text(songbookMargin, y, PDFBold, 20, 0, line)
+		y -= 24
+	}
+	text(songbookMargin, y, PDFRegular, 13, 0.3, truncatePDFText(song.Artist, PDFRegular, 13, width))
+	y -= 18
+
+	var details []string
+	if song.Album != "" {
+		details = append(details, song.Album)
+	}
+	details = append(details, "Released "+song.FormattedReleaseDate())
+	if song.Duration > 0 {
+		details = append(details, song.FormattedDuration())
+	}
+	text(songbookMargin, y, PDFRegular, 10, 0.45, truncatePDFText(strings.Join(details, " · "), PDFRegular, 10, width))
+	y -= 12
+	rule(y)
+	y -= 28
+
+	if s.Lyrics == nil {
+		text(songbookMargin, y, PDFItalic, songbookLyricSize, 0.4, "Lyrics not available for this song.")
+		return append(pages, current)
+	}
+
+	type printedLine struct {
+		font   PDFFont
+		gray   float64
+		indent float64
+		text   string
+	}
+	for _, stanza := range s.Lyrics.Stanzas {
+		var lines []printedLine
+		if stanza.Repeat {
+			label := stanza.Label
+			if label == "" {
+				label = "Repeated section"
+			}
+			lines = append(lines, printedLine{font: PDFItalic, gray: 0.4, text: "[" + label + "] (repeat)"})
+		} else {
+			if stanza.Label != "" {
+				lines = append(lines, printedLine{font: PDFItalic, gray: 0.4, text: "[" + stanza.Label + "]"})
+			}
+			for _, line := range stanza.Lines {
+				for i, part := range wrapPDFText(line, PDFRegular, songbookLyricSize, width-12) {
+					indent := 0.0
+					if i > 0 {
+						indent = 12
+					}
+					lines = append(lines, printedLine{font: PDFRegular, indent: indent, text: part})
+				}
+			}
+		}
+
+		height := float64(len(lines)) * songbookLeading
+		if y-height < songbookBottom && height <= songbookTop-34-songbookBottom {
+			newPage()
+		}
+		for _, line := range lines {
+			if y < songbookBottom {
+				newPage()
+			}
+			text(songbookMargin+line.indent, y, line.font, songbookLyricSize, line.gray, line.text)
+			y -= songbookLeading
+		}
+		y -= 8
+	}
+	return append(pages, current)
+}
+
+// wrapPDFText breaks text into lines no wider than width, between words. A
+// word wider than width gets a line of its own.
+func wrapPDFText(text string, font PDFFont, size, width float64) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if line != "" && PDFTextWidth(candidate, font, size) > width {
+			lines = append(lines, line)
+			candidate = word
+		}
+		line = candidate
+	}
+	if line != "" || len(lines) == 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// truncatePDFText shortens text with an ellipsis until it fits in width.
+func truncatePDFText(text string, font PDFFont, size, width float64) string {
+	if PDFTextWidth(text, font, size) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for n := len(runes) - 1; n > 0; n-- {
+		short := strings.TrimSpace(string(runes[:n])) + "…"
+		if PDFTextWidth(short, font, size) <= width {
+			return short
+		}
+	}
+	return ""
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWrapPDFText(t *testing.T) {
+	width := func(s string) float64 { return PDFTextWidth(s, PDFRegular, 10) }
+	tests := []struct {
+		name  string
+		text  string
+		width float64
+		want  []string
+	}{
+		{"fits", "one two", width("one two"), []string{"one two"}},
+		{"breaks between words", "nn nn nn", width("nn nn"), []string{"nn nn", "nn"}},
+		{"one word per line", "aaa bbb ccc", width("aaa"), []string{"aaa", "bbb", "ccc"}},
+		{"long word alone", "a verylongword b", width("a b"), []string{"a", "verylongword", "b"}},
+		{"collapses spaces", "  one \t two  ", width("one two"), []string{"one two"}},
+		{"empty", "", 100, []string{""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := wrapPDFText(tt.text, PDFRegular, 10, tt.width)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("wrapPDFText(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncatePDFText(t *testing.T) {
+	width := func(s string) float64 { return PDFTextWidth(s, PDFRegular, 10) }
+	tests := []struct {
+		text  string
+		width float64
+		want  string
+	}{
+		{"Short", width("Short"), "Short"},
+		{"A long title", width("A long…"), "A long…"},
+		{"A long title", width("A lo…"), "A lo…"},
+		{"Anything", 0, ""},
+	}
+	for _, tt := range tests {
+		if got := truncatePDFText(tt.text, PDFRegular, 10, tt.width); got != tt.want {
+			t.Errorf("truncatePDFText(%q, %v) = %q, want %q", tt.text, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestSongbookPDF(t *testing.T) {
+	lyrics := ParseLyrics("[Chorus]\nDon’t stop (me) now\n\nSecond verse")
+	book := Songbook{
+		Title: "Road Trip",
+		Songs: []SongbookSong{
+			{Song: Song{ID: "1", Title: "With Lyrics", Artist: "Someone"}, Lyrics: &lyrics},
+			{Song: Song{ID: "2", Title: "Without", Artist: "Someone Else"}},
+		},
+	}
+	pdf := string(book.PDF())
+	if !strings.HasPrefix(pdf, "%PDF-") || !strings.HasSuffix(strings.TrimSpace(pdf), "%%EOF") {
+		t.Fatalf("not a PDF: %.20q ... %.20q", pdf, pdf[len(pdf)-20:])
+	}
+	for _, want := range []string{`Don\222t stop \(me\) now`, "Lyrics not available", "Road Trip"} {
+		if !strings.Contains(pdf, want) {
+			t.Errorf("PDF does not contain %q", want)
+		}
+	}
+}
+
+func TestSongbookPDFFromIndex(t *testing.T) {
+	useTempLyricsIndex(t)
+
+	song := Song{ID: "indexed", Title: "From The Index", Artist: "Someone"}
+	lyrics := ParseLyrics("[Verse 1]\nFirst line here\n\n[Chorus]\nSing it loud\n\n[Verse 2]\nSecond verse\n\n[Chorus]\nSing it loud")
+	if err := IndexLyrics(song, &lyrics); err != nil {
+		t.Fatal(err)
+	}
+	if err := IndexLyrics(Song{ID: "missing"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	indexed, known := IndexedLyricsOf("indexed")
+	if !known || indexed == nil {
+		t.Fatalf("IndexedLyricsOf = %v, %v; want lyrics", indexed, known)
+	}
+	if !reflect.DeepEqual(indexed.Stanzas, lyrics.Stanzas) {
+		t.Errorf("stanzas from the index =\n%+v\nwant\n%+v", indexed.Stanzas, lyrics.Stanzas)
+	}
+	if missing, known := IndexedLyricsOf("missing"); !known || missing != nil {
+		t.Errorf("IndexedLyricsOf(missing) = %v, %v; want nil, true", missing, known)
+	}
+	if _, known := IndexedLyricsOf("never"); known {
+		t.Error("IndexedLyricsOf(never) is known")
+	}
+
+	book := Songbook{Title: "Indexed", Songs: []SongbookSong{{Song: song, Lyrics: indexed}}}
+	pdf := string(book.PDF())
+	for _, want := range []string{"[Verse 1]", "[Verse 2]", "[Chorus] \\(repeat\\)", "Sing it loud"} {
+		if !strings.Contains(pdf, want) {
+			t.Errorf("PDF does not contain %q", want)
+		}
+	}
+}
+
+func TestIndexedLyricsOfLegacyEntry(t *testing.T) {
+	useTempLyricsIndex(t)
+	if err := loadLyricsIndex(); err != nil {
+		t.Fatal(err)
+	}
+
+	lyricsIndex.Lock()
+	lyricsIndex.docs["old"] = &IndexedLyrics{ID: "old", Lines: []string{"only lines"}}
+	lyricsIndex.Unlock()
+
+	if has, known := HasIndexedLyrics("old"); !has || !known {
+		t.Fatalf("HasIndexedLyrics(old) = %v, %v; want the entry in place", has, known)
+	}
+	if lyrics, known := IndexedLyricsOf("old"); known || lyrics != nil {
+		t.Errorf("IndexedLyricsOf(old) = %v, %v; want it looked up again", lyrics, known)
+	}
+}
+
+// useTempLyricsIndex points the lyrics index at an empty file in a
+// temporary directory for the length of the test.
+func useTempLyricsIndex(t *testing.T) {
+	t.Helper()
+	path := LyricsIndexFile
+	reset := func() {
+		lyricsIndex.Lock()
+		if lyricsIndex.saveTimer != nil {
+			lyricsIndex.saveTimer.Stop()
+			lyricsIndex.saveTimer = nil
+		}
+		lyricsIndex.loaded, lyricsIndex.dirty = false, false
+		lyricsIndex.Unlock()
+	}
+	reset()
+	LyricsIndexFile = filepath.Join(t.TempDir(), "lyrics_index.json")
+	t.Cleanup(func() {
+		reset()
+		LyricsIndexFile = path
+	})
+}
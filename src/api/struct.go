@@ -28,25 +28,37 @@ type SearchFilters struct {
     MaxDuration int    `json:"maxDuration"`
     LyricsFilter string `json:"lyricsFilter"`
     PlaylistFilter string `json:"playlistFilter"`
+    Mood        string `json:"mood"`
+    Language    string `json:"language"`
 }
 
 type Song struct {
 	ID       string `json:"id"`
 	Title    string `json:"title"`
 	Artist   string `json:"artist"`
+	Album    string `json:"album,omitempty"`
 	Lyrics   string `json:"lyrics,omitempty"`
 	CoverURL string `json:"cover_url,omitempty"`
 	ReleaseDate time.Time `json:"release_date,omitempty"`
 	PreviewURL string `json:"preview_url,omitempty"`
     Duration    int       `json:"duration"`
     InPlaylist  bool      `json:"in_playlist"`
+    AddedBy     string    `json:"added_by,omitempty"`
+    AddedAt     time.Time `json:"added_at,omitempty"`
+    Sentiment   string    `json:"sentiment,omitempty"`
+    Moods       []string  `json:"moods,omitempty"`
+    Language    string    `json:"language,omitempty"`
 }
 
 type SpotifyTrack struct {
     ID         string `json:"id"`
     Name       string `json:"name"`
     DurationMs int    `json:"duration_ms"`
+    Artists    []struct {
+        Name string `json:"name"`
+    } `json:"artists"`
     Album      struct {
+        Name   string `json:"name"`
         Images []struct {
             URL string `json:"url"`
         } `json:"images"`
@@ -59,8 +71,17 @@ type Config struct {
 	SpotifyClientSecret string `json:"spotify_client_secret"`
 }
 
+// GeniusConfig holds the optional Genius credentials, read from
+// config.json at the project root.
+type GeniusConfig struct {
+	ClientID     string `json:"genius_client_id"`
+	ClientSecret string `json:"genius_client_secret"`
+	AccessToken  string `json:"genius_access_token"`
+}
+
 var (
     config             Config
+    geniusConfig       GeniusConfig
     spotifyAccessToken string
     spotifyTokenExpiry time.Time
 )
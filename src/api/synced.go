@@ -0,0 +1,166 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// SyncedLyricsDir holds uploaded LRC files, one per Spotify track ID.
+var SyncedLyricsDir = "data/synced"
+
+// maxSyncedQueries caps how many title/artist candidates are tried per
+// provider.
+const maxSyncedQueries = 3
+
+// SyncedLyricsProvider is a source that can return time-synced lyrics.
+type SyncedLyricsProvider interface {
+	Name() string
+	FetchSynced(ctx context.Context, title, artist string, durationMs int) (TimedLyrics, error)
+}
+
+var (
+	ErrNoSyncedLyrics = errors.New("no synced lyrics found")
+
+	validTrackID = regexp.MustCompile(`^[A-Za-z0-9]+$`)
+
+	syncedMu        sync.Mutex
+	syncedProviders = []SyncedLyricsProvider{lrclibProvider{}}
+)
+
+// RegisterSyncedProvider adds a provider after the existing ones.
+func RegisterSyncedProvider(p SyncedLyricsProvider) {
+	syncedMu.Lock()
+	defer syncedMu.Unlock()
+	syncedProviders = append(syncedProviders, p)
+}
+
+// FetchSyncedLyrics returns synced lyrics for a track: an uploaded LRC
+// file if there is one, otherwise the first provider that has the song.
+// Providers are not asked any more once ctx is done.
+func FetchSyncedLyrics(ctx context.Context, songID, title, artist string, durationMs int) (TimedLyrics, error) {
+	if stored, err := LoadSyncedLyrics(songID); err == nil {
+		return stored, nil
+	}
+
+	syncedMu.Lock()
+	providers := append([]SyncedLyricsProvider(nil), syncedProviders...)
+	syncedMu.Unlock()
+
+	queries := PlanLyricsQueries(title, artist)
+	if len(queries) > maxSyncedQueries {
+		queries = queries[:maxSyncedQueries]
+	}
+
+	for _, p := range providers {
+		for _, q := range queries {
+			if ctx.Err() != nil {
+				return TimedLyrics{}, ctx.Err()
+			}
+			lyrics, err := p.FetchSynced(ctx, q.Title, q.Artist, durationMs)
+			if err == nil {
+				lyrics.Source = p.Name()
+				return lyrics, nil
+			}
+			if err != ErrNoSyncedLyrics {
+				break
+			}
+		}
+	}
+	return TimedLyrics{}, ErrNoSyncedLyrics
+}
+
+// LoadSyncedLyrics reads the uploaded LRC file for a track.
+func LoadSyncedLyrics(songID string) (TimedLyrics, error) {
+	path, err := syncedPath(songID)
+	if err != nil {
+		return TimedLyrics{}, err
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return TimedLyrics{}, err
+	}
+
+	lyrics, err := ParseLRC(string(data))
+	if err != nil {
+		return TimedLyrics{}, err
+	}
+	lyrics.Source = "Harmonify"
+	return lyrics, nil
+}
+
+// SaveSyncedLyrics stores lyrics as the LRC file for a track, replacing
+// any earlier upload.
+func SaveSyncedLyrics(songID string, lyrics TimedLyrics) error {
+	path, err := syncedPath(songID)
+	if err != nil {
+		return err
+	}
+
+	syncedMu.Lock()
+	defer syncedMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, []byte(FormatLRC(lyrics)), 0644)
+}
+
+func syncedPath(songID string) (string, error) {
+	if !validTrackID.MatchString(songID) {
+		return "", fmt.Errorf("invalid track id: %q", songID)
+	}
+	return filepath.Join(SyncedLyricsDir, songID+".lrc"), nil
+}
+
+// lrclibProvider looks songs up on lrclib.net, which serves LRC lyrics
+// without an API key.
+type lrclibProvider struct{}
+
+func (lrclibProvider) Name() string { return "LRCLIB" }
+
+func (lrclibProvider) FetchSynced(ctx context.Context, title, artist string, durationMs int) (TimedLyrics, error) {
+	params := url.Values{}
+	params.Set("track_name", title)
+	params.Set("artist_name", artist)
+	if durationMs > 0 {
+		params.Set("duration", fmt.Sprint(durationMs/1000))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://lrclib.net/api/get?"+params.Encode(), nil)
+	if err != nil {
+		return TimedLyrics{}, err
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return TimedLyrics{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return TimedLyrics{}, ErrNoSyncedLyrics
+	}
+
+	var body struct {
+		SyncedLyrics string `json:"syncedLyrics"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return TimedLyrics{}, err
+	}
+	if body.SyncedLyrics == "" {
+		return TimedLyrics{}, ErrNoSyncedLyrics
+	}
+	return ParseLRC(body.SyncedLyrics)
+}
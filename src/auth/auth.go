@@ -7,12 +7,22 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sync"
 	"harmonify/src/api"
 )
 
 type User struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	Settings Settings `json:"settings"`
+}
+
+// Settings are a user's display preferences. ProfanityWords are masked
+// on top of the bundled list when MaskProfanity is on.
+type Settings struct {
+	MaskProfanity  bool     `json:"mask_profanity"`
+	ProfanityWords []string `json:"profanity_words,omitempty"`
 }
 
 type UserDB struct {
@@ -22,6 +32,15 @@ type UserDB struct {
 var (
 	UsersFile = "data/users.json"
 	userDB    UserDB
+
+	// userMu guards userDB, which requests read and update concurrently.
+	userMu sync.RWMutex
+
+	// validUsername is the charset playlist ids allow, since a user's
+	// playlists, shares and history are all stored under their name.
+	validUsername = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+	ErrInvalidUsername = errors.New("usernames may only contain letters, numbers, - and _")
 )
 
 func InitUserSystem() error {
@@ -34,10 +53,17 @@ func InitUserSystem() error {
 		return err
 	}
 
+	if err := initGuestKey(); err != nil {
+		return err
+	}
+
+	userMu.Lock()
+	defer userMu.Unlock()
+
 	if _, err := os.Stat(UsersFile); os.IsNotExist(err) {
 
 		userDB = UserDB{Users: []User{}}
-		return SaveUserDB()
+		return saveUserDB()
 	} else {
 
 		data, err := ioutil.ReadFile(UsersFile)
@@ -49,6 +75,12 @@ func InitUserSystem() error {
 }
 
 func SaveUserDB() error {
+	userMu.Lock()
+	defer userMu.Unlock()
+	return saveUserDB()
+}
+
+func saveUserDB() error {
 	data, err := json.MarshalIndent(userDB, "", "  ")
 	if err != nil {
 		return err
@@ -57,6 +89,13 @@ func SaveUserDB() error {
 }
 
 func RegisterUser(username, password string) error {
+	if !validUsername.MatchString(username) {
+		return ErrInvalidUsername
+	}
+
+	userMu.Lock()
+	defer userMu.Unlock()
+
 	for _, user := range userDB.Users {
 		if user.Username == username {
 			return errors.New("username already exists")
@@ -68,7 +107,7 @@ func RegisterUser(username, password string) error {
 		Password: password,
 	})
 
-	if err := SaveUserDB(); err != nil {
+	if err := saveUserDB(); err != nil {
 		return err
 	}
 
@@ -76,6 +115,9 @@ func RegisterUser(username, password string) error {
 }
 
 func AuthenticateUser(username, password string) bool {
+	userMu.RLock()
+	defer userMu.RUnlock()
+
 	for _, user := range userDB.Users {
 		if user.Username == username && user.Password == password {
 			return true
@@ -84,6 +126,46 @@ func AuthenticateUser(username, password string) bool {
 	return false
 }
 
+func UserExists(username string) bool {
+	userMu.RLock()
+	defer userMu.RUnlock()
+
+	for _, user := range userDB.Users {
+		if user.Username == username {
+			return true
+		}
+	}
+	return false
+}
+
+// UserSettings returns the settings of username, or the defaults when
+// there is no such user.
+func UserSettings(username string) Settings {
+	userMu.RLock()
+	defer userMu.RUnlock()
+
+	for _, user := range userDB.Users {
+		if user.Username == username {
+			return user.Settings
+		}
+	}
+	return Settings{}
+}
+
+// SaveUserSettings replaces the settings of username.
+func SaveUserSettings(username string, settings Settings) error {
+	userMu.Lock()
+	defer userMu.Unlock()
+
+	for i, user := range userDB.Users {
+		if user.Username == username {
+			userDB.Users[i].Settings = settings
+			return saveUserDB()
+		}
+	}
+	return errors.New("user not found")
+}
+
 func GetUserPlaylistPath(username string) string {
 	return filepath.Join("data/playlists", fmt.Sprintf("%s_playlist.json", username))
 }
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+var (
+	GuestKeyFile = "data/guest.key"
+	guestKey     []byte
+)
+
+func initGuestKey() error {
+	if data, err := ioutil.ReadFile(GuestKeyFile); err == nil {
+		guestKey, err = hex.DecodeString(strings.TrimSpace(string(data)))
+		return err
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	guestKey = make([]byte, 32)
+	if _, err := rand.Read(guestKey); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(GuestKeyFile, []byte(hex.EncodeToString(guestKey)), 0600)
+}
+
+func NewGuestToken() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(b)
+	return id + "." + signGuestID(id), nil
+}
+
+// VerifyGuestToken returns the guest ID carried by a token created with
+// NewGuestToken, or false if the token was tampered with.
+func VerifyGuestToken(token string) (string, bool) {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", false
+	}
+	if !hmac.Equal([]byte(parts[1]), []byte(signGuestID(parts[0]))) {
+		return "", false
+	}
+	return parts[0], true
+}
+
+func signGuestID(id string) string {
+	mac := hmac.New(sha256.New, guestKey)
+	mac.Write([]byte(id))
+	return hex.EncodeToString(mac.Sum(nil))
+}
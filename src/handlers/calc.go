@@ -1,14 +1,14 @@
 package handlers
 
 import (
-	"encoding/json"
 	"fmt"
 	"harmonify/src/api"
+	"harmonify/src/auth"
+	"harmonify/src/playlist"
 	"html/template"
-	"io/ioutil"
+	"log"
 	"net/http"
-	"os"
-	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -27,6 +27,14 @@ var (
 var (
 	LoginTemplate  *template.Template
 	RegisterTemplate *template.Template
+	SharedTemplate   *template.Template
+	PlaylistsTemplate      *template.Template
+	CollabPlaylistTemplate *template.Template
+	HistoryTemplate        *template.Template
+	SmartPlaylistsTemplate *template.Template
+	SmartPlaylistTemplate  *template.Template
+	GenerateTemplate       *template.Template
+	SettingsTemplate       *template.Template
 )
 
 type Session struct {
@@ -37,55 +45,95 @@ type Session struct {
 
 var activeSessions = make(map[string]Session)
 
+const guestCookieName = "guest_id"
+
 func init() {
     r, _ := http.NewRequest("GET", "/", nil)
     LoadPlaylistFromFile(r)
 }
 
 func LoadPlaylistFromFile(r *http.Request) ([]api.Song, error) {
-    _, username, loggedIn := getSessionInfo(r)
-    playlistFile := "default.json"
+    return playlist.Load(currentPlaylistID(r))
+}
 
-    if loggedIn {
-        playlistFile = fmt.Sprintf("%s_playlist.json", username)
+func currentPlaylistID(r *http.Request) string {
+    if _, username, loggedIn := getSessionInfo(r); loggedIn {
+        return playlist.UserPlaylistID(username)
     }
-    playlistPath := filepath.Join("data", "playlists", playlistFile)
-
-    if _, err := os.Stat(playlistPath); os.IsNotExist(err) {
-        return []api.Song{}, nil
+    if guest, ok := getGuestID(r); ok {
+        return playlist.GuestPlaylistID(guest)
     }
-    data, err := ioutil.ReadFile(playlistPath)
+    return playlist.DefaultID
+}
 
+func getGuestID(r *http.Request) (string, bool) {
+    cookie, err := r.Cookie(guestCookieName)
     if err != nil {
-        return nil, err
+        return "", false
     }
-    var playlist []api.Song
-
-    if err := json.Unmarshal(data, &playlist); err != nil {
-        return nil, err
-    }
-    return playlist, nil
+    return auth.VerifyGuestToken(cookie.Value)
 }
 
-func SavePlaylistToFile(r *http.Request) error {
-    _, username, loggedIn := getSessionInfo(r)
+// GuestMiddleware gives every anonymous visitor a signed guest cookie so
+// they get a playlist of their own. The cookie is also added to the
+// incoming request so handlers see it on the very first visit.
+func GuestMiddleware(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if !strings.HasPrefix(r.URL.Path, "/static/") {
+            ensureGuestCookie(w, r)
+        }
+        next.ServeHTTP(w, r)
+    })
+}
 
-    playlistFile := "default.json"
-    if loggedIn {
-        playlistFile = fmt.Sprintf("%s_playlist.json", username)
+func ensureGuestCookie(w http.ResponseWriter, r *http.Request) {
+    if _, _, loggedIn := getSessionInfo(r); loggedIn {
+        return
     }
-
-    if err := os.MkdirAll("data/playlists", 0755); err != nil {
-        return err
+    if _, ok := getGuestID(r); ok {
+        return
     }
 
-    playlistPath := filepath.Join("data", "playlists", playlistFile)
-    data, err := json.MarshalIndent(Playlist, "", "  ")
+    token, err := auth.NewGuestToken()
     if err != nil {
-        return err
+        log.Printf("Failed to create guest token: %v", err)
+        return
     }
 
-    return ioutil.WriteFile(playlistPath, data, 0644)
+    cookie := &http.Cookie{
+        Name:     guestCookieName,
+        Value:    token,
+        Path:     "/",
+        MaxAge:   int(playlist.GuestPlaylistTTL.Seconds()),
+        HttpOnly: true,
+    }
+    http.SetCookie(w, cookie)
+
+    cookies := r.Cookies()
+    r.Header.Del("Cookie")
+    for _, c := range cookies {
+        if c.Name != guestCookieName {
+            r.AddCookie(c)
+        }
+    }
+    r.AddCookie(cookie)
+}
+
+func clearGuestCookie(w http.ResponseWriter) {
+    http.SetCookie(w, &http.Cookie{
+        Name:     guestCookieName,
+        Value:    "",
+        Path:     "/",
+        MaxAge:   -1,
+        HttpOnly: true,
+    })
+}
+
+func actorName(r *http.Request) string {
+    if _, username, loggedIn := getSessionInfo(r); loggedIn {
+        return username
+    }
+    return "guest"
 }
 
 func getSessionInfo(r *http.Request) (string, string, bool) {
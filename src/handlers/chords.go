@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+
+	"harmonify/src/api"
+)
+
+const maxChordSheetSize = 256 << 10
+
+// HandleUploadChords attaches a ChordPro chord sheet to a track, from an
+// uploaded file or pasted text. Like synced lyrics, chord sheets are
+// shared: every visitor of the track sees them.
+func HandleUploadChords(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 2*maxChordSheetSize+4096)
+	if err := r.ParseMultipartForm(maxChordSheetSize); err != nil {
+		http.Error(w, "Upload too large or malformed", http.StatusBadRequest)
+		return
+	}
+
+	songID := r.FormValue("id")
+	back := lyricsPageURL(songID, r.FormValue("title"), r.FormValue("artist"), r.FormValue("query"), r.FormValue("page"))
+
+	text := r.FormValue("chordpro_text")
+	if file, _, err := r.FormFile("chordpro"); err == nil {
+		defer file.Close()
+		data, err := ioutil.ReadAll(file)
+		if err != nil {
+			http.Error(w, "Error reading upload", http.StatusBadRequest)
+			return
+		}
+		text = string(data)
+	}
+	if strings.TrimSpace(text) == "" {
+		http.Redirect(w, r, back+"&action=chords_invalid", http.StatusSeeOther)
+		return
+	}
+
+	if err := api.SaveChordSheet(songID, text); err == api.ErrNoChords {
+		http.Redirect(w, r, back+"&action=chords_invalid", http.StatusSeeOther)
+		return
+	} else if err != nil {
+		log.Printf("Failed to save chord sheet for %s: %v", songID, err)
+		http.Redirect(w, r, back+"&action=failed", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, back+"&action=chords_saved", http.StatusSeeOther)
+}
+
+// HandleDeleteChords removes the chord sheet of a track.
+func HandleDeleteChords(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	songID := r.FormValue("id")
+	back := lyricsPageURL(songID, r.FormValue("title"), r.FormValue("artist"), r.FormValue("query"), r.FormValue("page"))
+
+	if err := api.DeleteChordSheet(songID); err != nil {
+		log.Printf("Failed to delete chord sheet for %s: %v", songID, err)
+		http.Redirect(w, r, back+"&action=failed", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, back+"&action=chords_removed", http.StatusSeeOther)
+}
@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"harmonify/src/api"
+	"harmonify/src/playlist"
+)
+
+func HandlePlaylists(w http.ResponseWriter, r *http.Request) {
+	_, username, _ := getSessionInfo(r)
+
+	playlists, err := playlist.PlaylistsFor(username)
+	if err != nil {
+		log.Printf("Error loading playlists: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	invites, err := playlist.PendingInvites(username)
+	if err != nil {
+		log.Printf("Error loading invites: %v", err)
+	}
+
+	sources, err := playlist.Accessible(username)
+	if err != nil {
+		log.Printf("Error loading playlists: %v", err)
+	}
+
+	data := struct {
+		Username  string
+		Playlists []playlist.Meta
+		Invites   []playlist.Meta
+		Sources   []playlist.Meta
+	}{
+		Username:  username,
+		Playlists: playlists,
+		Invites:   invites,
+		Sources:   sources,
+	}
+
+	if err := PlaylistsTemplate.Execute(w, data); err != nil {
+		log.Printf("Error rendering playlists template: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+func HandleCreatePlaylist(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	_, username, _ := getSessionInfo(r)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	meta, err := playlist.CreateCollaborative(username, r.FormValue("name"))
+	if err != nil {
+		log.Printf("Failed to create playlist: %v", err)
+		http.Redirect(w, r, "/playlists?action=failed", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, collabURL(meta.ID, "created"), http.StatusSeeOther)
+}
+
+func HandleViewPlaylist(w http.ResponseWriter, r *http.Request) {
+	_, username, _ := getSessionInfo(r)
+	id := r.URL.Query().Get("id")
+
+	meta, err := playlist.GetMeta(id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	role := meta.RoleOf(username)
+	if !role.CanView() {
+		http.Error(w, playlist.ErrForbidden.Error(), http.StatusForbidden)
+		return
+	}
+
+	songs, err := playlist.Load(id)
+	if err != nil {
+		log.Printf("Error loading playlist %s: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		Meta     playlist.Meta
+		Username string
+		Role     playlist.Role
+		CanEdit  bool
+		IsOwner  bool
+		Playlist []api.Song
+	}{
+		Meta:     meta,
+		Username: username,
+		Role:     role,
+		CanEdit:  role.CanEdit(),
+		IsOwner:  role == playlist.RoleOwner,
+		Playlist: songs,
+	}
+
+	if err := CollabPlaylistTemplate.Execute(w, data); err != nil {
+		log.Printf("Error rendering collaborative playlist template: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+func HandleInviteToPlaylist(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	_, username, _ := getSessionInfo(r)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	id := r.FormValue("id")
+	err := playlist.InviteUser(id, username, r.FormValue("username"), playlist.Role(r.FormValue("role")))
+	if err != nil {
+		log.Printf("Failed to invite to playlist %s: %v", id, err)
+		http.Redirect(w, r, collabURL(id, "invite_failed"), http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, collabURL(id, "invited"), http.StatusSeeOther)
+}
+
+func HandleRespondToInvite(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	_, username, _ := getSessionInfo(r)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	id := r.FormValue("id")
+	accept := r.FormValue("accept") == "yes"
+	if err := playlist.RespondToInvite(id, username, accept); err != nil {
+		log.Printf("Failed to respond to invite for %s: %v", id, err)
+		http.Redirect(w, r, "/playlists?action=failed", http.StatusSeeOther)
+		return
+	}
+
+	if accept {
+		http.Redirect(w, r, collabURL(id, "joined"), http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/playlists?action=declined", http.StatusSeeOther)
+}
+
+func HandleRemoveMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	_, username, _ := getSessionInfo(r)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	id := r.FormValue("id")
+	member := r.FormValue("username")
+	if err := playlist.RemoveMember(id, username, member); err != nil {
+		log.Printf("Failed to remove %s from playlist %s: %v", member, id, err)
+		http.Redirect(w, r, collabURL(id, "failed"), http.StatusSeeOther)
+		return
+	}
+
+	if member == username {
+		http.Redirect(w, r, "/playlists?action=left", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, collabURL(id, "member_removed"), http.StatusSeeOther)
+}
+
+func HandleRemoveFromCollaborative(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	_, username, _ := getSessionInfo(r)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	id := r.FormValue("id")
+	if !playlist.RoleFor(id, username).CanEdit() {
+		http.Error(w, playlist.ErrForbidden.Error(), http.StatusForbidden)
+		return
+	}
+
+	if _, err := playlist.RemoveSong(id, username, r.FormValue("song")); err != nil {
+		if err == playlist.ErrSongNotFound {
+			http.Redirect(w, r, collabURL(id, "not_found"), http.StatusSeeOther)
+			return
+		}
+		log.Printf("Failed to remove song from playlist %s: %v", id, err)
+		http.Redirect(w, r, collabURL(id, "failed"), http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, collabURL(id, "removed"), http.StatusSeeOther)
+}
+
+func handleAddToCollaborative(w http.ResponseWriter, r *http.Request, id, username, songId, title, artist string) {
+	if !playlist.RoleFor(id, username).CanEdit() {
+		http.Error(w, playlist.ErrForbidden.Error(), http.StatusForbidden)
+		return
+	}
+
+	existing, err := playlist.Load(id)
+	if err != nil {
+		log.Printf("Error loading playlist %s: %v", id, err)
+		http.Redirect(w, r, collabURL(id, "failed"), http.StatusSeeOther)
+		return
+	}
+	if confirm := versionConfirmation(r, existing, api.Song{ID: songId, Title: title, Artist: artist}); confirm != "" {
+		http.Redirect(w, r, collabURL(id, "version_exists")+confirm, http.StatusSeeOther)
+		return
+	}
+
+	song, err := fetchPlaylistSong(songId, title, artist)
+	if err != nil {
+		log.Printf("Failed to fetch song details: %v", err)
+		http.Redirect(w, r, collabURL(id, "failed"), http.StatusSeeOther)
+		return
+	}
+	song.AddedBy = username
+
+	if _, err := playlist.AddSong(id, username, song); err != nil {
+		if err == playlist.ErrDuplicateSong {
+			http.Redirect(w, r, collabURL(id, "already_exists"), http.StatusSeeOther)
+			return
+		}
+		log.Printf("Failed to add song to playlist %s: %v", id, err)
+		http.Redirect(w, r, collabURL(id, "failed"), http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, collabURL(id, "added"), http.StatusSeeOther)
+}
+
+func collabURL(id, action string) string {
+	return fmt.Sprintf("/playlists/view?id=%s&action=%s", url.QueryEscape(id), action)
+}
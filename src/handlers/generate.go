@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"harmonify/src/api"
+	"harmonify/src/playlist"
+)
+
+const generatorSearchPages = 3
+
+var errInvalidTarget = errors.New("target length must be greater than zero")
+
+func HandleGeneratePlaylist(w http.ResponseWriter, r *http.Request) {
+	_, username, _ := getSessionInfo(r)
+	params := r.URL.Query()
+
+	if params.Get("seed") == "" {
+		params.Set("seed", strconv.FormatInt(time.Now().UnixNano(), 10))
+	}
+
+	sources, err := playlist.Accessible(username)
+	if err != nil {
+		log.Printf("Error loading playlists: %v", err)
+	}
+
+	var result *playlist.FitResult
+	opts, genErr := fitOptions(params)
+	if genErr == nil && params.Get("minutes") != "" {
+		var fit playlist.FitResult
+		fit, genErr = runGenerator(username, params, opts)
+		if genErr == nil {
+			result = &fit
+		}
+	}
+
+	errMessage := ""
+	if genErr != nil && params.Get("minutes") != "" {
+		errMessage = genErr.Error()
+	}
+
+	data := struct {
+		Sources []playlist.Meta
+		Params  url.Values
+		Options playlist.FitOptions
+		Result  *playlist.FitResult
+		Error   string
+	}{
+		Sources: sources,
+		Params:  params,
+		Options: opts,
+		Result:  result,
+		Error:   errMessage,
+	}
+
+	if err := GenerateTemplate.Execute(w, data); err != nil {
+		log.Printf("Error rendering generate template: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+func HandleSaveGeneratedPlaylist(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	_, username, _ := getSessionInfo(r)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	opts, err := fitOptions(r.PostForm)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := runGenerator(username, r.PostForm, opts)
+	if err != nil {
+		log.Printf("Failed to generate playlist: %v", err)
+		http.Redirect(w, r, "/generate?action=failed", http.StatusSeeOther)
+		return
+	}
+
+	name := strings.TrimSpace(r.PostFormValue("name"))
+	if name == "" {
+		name = api.FormatDurationMs(result.TotalMs) + " mix"
+	}
+
+	meta, err := playlist.CreateCollaborative(username, name)
+	if err == nil {
+		_, err = playlist.Update(meta.ID, username, playlist.EventImport, func(songs []api.Song) ([]api.Song, error) {
+			return append(songs, result.Songs...), nil
+		})
+	}
+	if err != nil {
+		log.Printf("Failed to save generated playlist: %v", err)
+		http.Redirect(w, r, "/generate?action=failed", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, collabURL(meta.ID, "created"), http.StatusSeeOther)
+}
+
+func fitOptions(params url.Values) (playlist.FitOptions, error) {
+	minutes, _ := strconv.ParseFloat(params.Get("minutes"), 64)
+	tolerance, err := strconv.ParseFloat(params.Get("tolerance"), 64)
+	if err != nil || tolerance < 0 {
+		tolerance = 2
+	}
+	seed, _ := strconv.ParseInt(params.Get("seed"), 10, 64)
+
+	opts := playlist.FitOptions{
+		TargetMs:           int(minutes * 60 * 1000),
+		ToleranceMs:        int(tolerance * 60 * 1000),
+		AvoidRepeatArtists: params.Get("avoidRepeats") == "on",
+		StartDate:          params.Get("startDate"),
+		EndDate:            params.Get("endDate"),
+		Seed:               seed,
+	}
+	if opts.TargetMs <= 0 {
+		return opts, errInvalidTarget
+	}
+	return opts, nil
+}
+
+func runGenerator(username string, params url.Values, opts playlist.FitOptions) (playlist.FitResult, error) {
+	var candidates []api.Song
+
+	if query := strings.TrimSpace(params.Get("query")); query != "" {
+		for page := 1; page <= generatorSearchPages; page++ {
+			songs, _, err := api.SearchSpotifySongs(query, page, api.SearchFilters{})
+			if err != nil {
+				return playlist.FitResult{}, err
+			}
+			candidates = append(candidates, songs...)
+		}
+	} else {
+		source := params.Get("source")
+		if source == "" {
+			source = playlist.UserPlaylistID(username)
+		}
+		if !playlist.RoleFor(source, username).CanView() {
+			return playlist.FitResult{}, playlist.ErrForbidden
+		}
+		songs, err := playlist.Load(source)
+		if err != nil {
+			return playlist.FitResult{}, err
+		}
+		candidates = songs
+	}
+
+	return playlist.FitDuration(candidates, opts), nil
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"harmonify/src/playlist"
+)
+
+func HandlePlaylistHistory(w http.ResponseWriter, r *http.Request) {
+	id, ok := editablePlaylistID(r, r.URL.Query().Get("id"))
+	if !ok {
+		http.Error(w, playlist.ErrForbidden.Error(), http.StatusForbidden)
+		return
+	}
+
+	history, err := playlist.GetHistory(id)
+	if err != nil {
+		log.Printf("Error loading history for %s: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	events := make([]playlist.Event, 0, len(history.Events))
+	for i := len(history.Events) - 1; i >= 0; i-- {
+		events = append(events, history.Events[i])
+	}
+
+	name, backURL := "Your Playlist", "/playlist"
+	if meta, err := playlist.GetMeta(id); err == nil {
+		name, backURL = meta.Name, collabURL(id, "")
+	}
+
+	data := struct {
+		ID      string
+		Name    string
+		BackURL string
+		Events  []playlist.Event
+		Trash   []playlist.TrashItem
+	}{
+		ID:      id,
+		Name:    name,
+		BackURL: backURL,
+		Events:  events,
+		Trash:   history.Trash,
+	}
+
+	if err := HistoryTemplate.Execute(w, data); err != nil {
+		log.Printf("Error rendering history template: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+func HandleUndoPlaylistChange(w http.ResponseWriter, r *http.Request) {
+	handleHistoryAction(w, r, "undone", func(id string) error {
+		_, err := playlist.Undo(id, actorName(r))
+		return err
+	})
+}
+
+func HandleRestorePlaylist(w http.ResponseWriter, r *http.Request) {
+	handleHistoryAction(w, r, "restored", func(id string) error {
+		eventID, err := strconv.Atoi(r.FormValue("event"))
+		if err != nil {
+			return playlist.ErrEventNotFound
+		}
+		_, err = playlist.RestoreTo(id, actorName(r), eventID)
+		return err
+	})
+}
+
+func HandleRestoreFromTrash(w http.ResponseWriter, r *http.Request) {
+	handleHistoryAction(w, r, "restored", func(id string) error {
+		_, err := playlist.RestoreFromTrash(id, actorName(r), r.FormValue("song"))
+		return err
+	})
+}
+
+func handleHistoryAction(w http.ResponseWriter, r *http.Request, success string, action func(id string) error) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	id, ok := editablePlaylistID(r, r.FormValue("id"))
+	if !ok {
+		http.Error(w, playlist.ErrForbidden.Error(), http.StatusForbidden)
+		return
+	}
+
+	result := success
+	if err := action(id); err != nil {
+		log.Printf("History action on %s failed: %v", id, err)
+		result = "failed"
+		if err == playlist.ErrNothingToUndo {
+			result = "nothing_to_undo"
+		}
+	}
+
+	if id == currentPlaylistID(r) {
+		if songs, err := playlist.Load(id); err == nil {
+			Playlist = songs
+		}
+	}
+
+	redirectURL := fmt.Sprintf("/playlist/history?id=%s&action=%s", url.QueryEscape(id), result)
+	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+}
+
+// editablePlaylistID resolves an optional playlist id from the request to
+// the playlist the visitor is allowed to change. An empty id means the
+// visitor's own playlist.
+func editablePlaylistID(r *http.Request, id string) (string, bool) {
+	current := currentPlaylistID(r)
+	if id == "" || id == current {
+		return current, true
+	}
+
+	_, username, _ := getSessionInfo(r)
+	return id, playlist.RoleFor(id, username).CanEdit()
+}
@@ -7,6 +7,7 @@ import (
 
 	"harmonify/src/api"
 	"harmonify/src/auth"
+	playlistpkg "harmonify/src/playlist"
 )
 
 func HandleLogin(w http.ResponseWriter, r *http.Request) {
@@ -43,6 +44,7 @@ func HandleLogin(w http.ResponseWriter, r *http.Request) {
 				HttpOnly: true,
 			}
 			http.SetCookie(w, &cookie)
+			mergeGuestPlaylist(w, r, username)
 
 			playlist, err := auth.LoadUserPlaylist(username)
 			if err != nil {
@@ -72,13 +74,9 @@ func HandleLogin(w http.ResponseWriter, r *http.Request) {
 }
 
 func HandleLogout(w http.ResponseWriter, r *http.Request) {
-    sessionID, username, loggedIn := getSessionInfo(r)
+    sessionID, _, loggedIn := getSessionInfo(r)
     
     if loggedIn {
-        if err := auth.SaveUserPlaylist(username, Playlist); err != nil {
-            log.Printf("Error saving user playlist: %v", err)
-        }
-        
         delete(activeSessions, sessionID)
     
         cookie := http.Cookie{
@@ -158,12 +156,30 @@ func HandleRegister(w http.ResponseWriter, r *http.Request) {
 			}
 			return
 		}
+		mergeGuestPlaylist(w, r, username)
 		http.Redirect(w, r, "/login", http.StatusSeeOther)
 		return
 	}
 	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 }
 
+func mergeGuestPlaylist(w http.ResponseWriter, r *http.Request, username string) {
+	guest, ok := getGuestID(r)
+	if !ok {
+		return
+	}
+
+	merged, err := playlistpkg.MergeInto(playlistpkg.UserPlaylistID(username), playlistpkg.GuestPlaylistID(guest), username)
+	if err != nil {
+		log.Printf("Error merging guest playlist into %s: %v", username, err)
+		return
+	}
+	if merged > 0 {
+		log.Printf("Merged %d guest songs into %s's playlist", merged, username)
+	}
+	clearGuestCookie(w)
+}
+
 func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
@@ -1,15 +1,22 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"log"
+	"mime"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 
 	"harmonify/src/api"
+	"harmonify/src/playlist"
 )
 
+const lyricsNotAvailable = "Lyrics not available for this song"
+
 func HandleLyrics(w http.ResponseWriter, r *http.Request) {
     songTitle, _ := url.QueryUnescape(r.URL.Query().Get("title"))
     artist := r.URL.Query().Get("artist")
@@ -18,16 +25,24 @@ func HandleLyrics(w http.ResponseWriter, r *http.Request) {
     page := r.URL.Query().Get("page")
     pageNum, _ := strconv.Atoi(page)
     actionMessage := r.URL.Query().Get("action")
+    mode := r.URL.Query().Get("mode")
 
     if pageNum == 0 {
         pageNum = 1
     }
 
-    lyrics, err := api.FetchLyricsOvh(songTitle, artist)
-    if err != nil {
-        log.Printf("Lyrics fetch error: %v", err)
-        lyrics = "Lyrics not available for this song"
-    }
+    // The plain and the synced lyrics are looked up at the same time, under
+    // one deadline, so a slow provider can't hold the page up past it.
+    ctx, cancel := context.WithTimeout(r.Context(), api.LyricsLookupTimeout)
+    defer cancel()
+
+    var lyrics api.ParsedLyrics
+    var matchedQuery *api.LyricsQuery
+    fetched := make(chan struct{})
+    go func() {
+        defer close(fetched)
+        lyrics, matchedQuery = fetchLyricsForPage(ctx, songTitle, artist)
+    }()
 
     previewURL, _ := api.SearchSpotifyMusicSource(songTitle, artist)
     spotifyURL := fmt.Sprintf("https://open.spotify.com/track/%s", songID)
@@ -54,34 +69,169 @@ if spotifyTrack != nil {
         }
     }
 
+    durationMs := 0
+    if spotifyTrack != nil {
+        durationMs = spotifyTrack.DurationMs
+    }
+    var synced *api.TimedLyrics
+    if timed, err := api.FetchSyncedLyrics(ctx, songID, songTitle, artist, durationMs); err == nil {
+        synced = &timed
+    }
+
+    <-fetched
+    rememberLyrics(api.Song{ID: songID, Title: songTitle, Artist: artist}, lyrics)
+
+    var mood *api.Mood
+    var language *api.Language
+    var similar []api.SimilarSong
+    var analysis *api.LyricsAnalysis
+    var rhymes *api.RhymeAnalysis
+    if lyrics.Raw != lyricsNotAvailable {
+        m := api.AnalyzeMood(lyrics)
+        mood = &m
+        language = lyricsLanguage(lyrics)
+        similar = api.SimilarLyrics(api.Song{ID: songID, Title: songTitle, Artist: artist}, lyrics)
+        a := api.AnalyzeLyrics(lyrics)
+        analysis = &a
+        rh := api.AnalyzeRhymes(lyrics)
+        rhymes = &rh
+    }
+
+    // Masking only changes what is shown, so it comes after the mood,
+    // language, stats and rhymes are worked out from the real words.
+    masker := profanityMasker(r)
+    masked := 0
+    if masker != nil {
+        lyrics, masked = masker.MaskLyrics(lyrics)
+        if synced != nil {
+            timed := masker.MaskTimed(*synced)
+            synced = &timed
+        }
+        for i := range similar {
+            terms := make([]string, len(similar[i].Terms))
+            for j, term := range similar[i].Terms {
+                terms[j], _ = masker.Mask(term)
+            }
+            similar[i].Terms = terms
+        }
+        if analysis != nil {
+            a := masker.MaskAnalysis(*analysis)
+            analysis = &a
+        }
+        if rhymes != nil {
+            rh := masker.MaskRhymes(*rhymes)
+            rhymes = &rh
+        }
+    }
+
+    // Chord sheets are transposed on the server, so the page prints as it
+    // is shown. Capo suggestions are for the chords after transposing.
+    transpose, _ := strconv.Atoi(r.URL.Query().Get("transpose"))
+    transpose %= 12
+    accidentals := r.URL.Query().Get("accidentals")
+    var chords *api.ChordSheet
+    var capos []api.CapoSuggestion
+    if sheet, err := api.LoadChordSheet(songID); err == nil {
+        sheet = sheet.Transpose(transpose, accidentals)
+        if masker != nil {
+            sheet = masker.MaskChordSheet(sheet)
+        }
+        chords = &sheet
+        capos = api.SuggestCapo(sheet)
+    }
+
+    // The sync editor starts from the existing synced lines when there are
+    // any, otherwise from the plain lyrics. It is left out while masking,
+    // since saving it would store the masked words.
+    var syncLines []string
+    if masker == nil && synced != nil {
+        for _, line := range synced.Lines {
+            syncLines = append(syncLines, line.Text)
+        }
+    } else if masker == nil && lyrics.Raw != lyricsNotAvailable {
+        for _, stanza := range lyrics.Stanzas {
+            syncLines = append(syncLines, stanza.Lines...)
+        }
+    }
+
+    var collaborative []playlist.Meta
+    _, username, loggedIn := getSessionInfo(r)
+    if loggedIn {
+        playlists, err := playlist.PlaylistsFor(username)
+        if err != nil {
+            log.Printf("Error loading collaborative playlists: %v", err)
+        }
+        for _, meta := range playlists {
+            if meta.RoleOf(username).CanEdit() {
+                collaborative = append(collaborative, meta)
+            }
+        }
+    }
+
     data := struct {
         ID                   string
         Title                string
         Artist               string
         Lyrics               string
+        Parsed               api.ParsedLyrics
         PreviewURL           string
         SpotifyURL           string
         InPlaylist           bool
         ActionMessage        string
         Query                string
+        Mode                 string
         Page                 int
         CoverURL             string
         FormattedReleaseDate string
         FormattedDuration    string
+        MatchedQuery         *api.LyricsQuery
+        Analysis             *api.LyricsAnalysis
+        Rhymes               *api.RhymeAnalysis
+        Mood                 *api.Mood
+        Language             *api.Language
+        Similar              []api.SimilarSong
+        Masking              bool
+        MaskedWords          int
+        Chords               *api.ChordSheet
+        Capos                []api.CapoSuggestion
+        Transpose            int
+        Accidentals          string
+        Synced               *api.TimedLyrics
+        SyncLines            []string
+        LoggedIn             bool
+        Collaborative        []playlist.Meta
     }{
         ID:                   songID,
         Title:                songTitle,
         Artist:               artist,
-        Lyrics:               lyrics,
+        Lyrics:               lyrics.Raw,
+        Parsed:               lyrics,
         PreviewURL:           previewURL,
         SpotifyURL:           spotifyURL,
         InPlaylist:           inPlaylist,
         ActionMessage:        actionMessage,
         Query:                query,
+        Mode:                 mode,
         Page:                 pageNum,
         CoverURL:             coverURL,
         FormattedReleaseDate: releaseDate,
         FormattedDuration:    duration,
+        MatchedQuery:         matchedQuery,
+        Analysis:             analysis,
+        Rhymes:               rhymes,
+        Mood:                 mood,
+        Language:             language,
+        Similar:              similar,
+        Masking:              masker != nil,
+        MaskedWords:          masked,
+        Chords:               chords,
+        Capos:                capos,
+        Transpose:            transpose,
+        Accidentals:          accidentals,
+        Synced:               synced,
+        SyncLines:            syncLines,
+        LoggedIn:             loggedIn,
+        Collaborative:        collaborative,
     }
 
     if err := LyricsTemplate.Execute(w, data); err != nil {
@@ -91,20 +241,128 @@ if spotifyTrack != nil {
     }
 }
 
-func HandleGetLyricsText(w http.ResponseWriter, r *http.Request) {
+// HandleDownloadLyrics serves a song's lyrics as a file. The song is
+// resolved from its Spotify ID; format is txt (default), lrc, md or json.
+func HandleDownloadLyrics(w http.ResponseWriter, r *http.Request) {
     if r.Method != http.MethodGet {
         http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
         return
     }
 
-    lyrics := r.URL.Query().Get("lyrics")
-    if lyrics == "" {
-        http.Error(w, "No lyrics provided", http.StatusBadRequest)
+    format := r.URL.Query().Get("format")
+    if format == "" {
+        format = "txt"
+    }
+    contentType, ok := api.ExportFormats[format]
+    if !ok {
+        http.Error(w, "Unsupported format", http.StatusBadRequest)
+        return
+    }
+
+    track, err := api.FetchSpotifyTrack(r.URL.Query().Get("id"))
+    if err != nil {
+        log.Printf("Error fetching Spotify track for download: %v", err)
+        http.NotFound(w, r)
+        return
+    }
+
+    artists := make([]string, 0, len(track.Artists))
+    for _, artist := range track.Artists {
+        artists = append(artists, artist.Name)
+    }
+
+    export := api.LyricsExport{
+        ID:     track.ID,
+        Title:  track.Name,
+        Artist: strings.Join(artists, ", "),
+        Album:  track.Album.Name,
+    }
+
+    ctx, cancel := context.WithTimeout(r.Context(), api.LyricsLookupTimeout)
+    defer cancel()
+
+    var synced api.TimedLyrics
+    var syncedErr error
+    fetched := make(chan struct{})
+    go func() {
+        defer close(fetched)
+        synced, syncedErr = api.FetchSyncedLyrics(ctx, track.ID, export.Title, export.Artist, track.DurationMs)
+    }()
+
+    result, err := api.FetchLyrics(ctx, export.Title, export.Artist)
+    <-fetched
+    if syncedErr == nil {
+        export.Synced = &synced
+    }
+
+    if err == nil {
+        export.Lyrics, export.Source = result.Parsed, "lyrics.ovh"
+    } else if export.Synced != nil {
+        export.Lyrics, export.Source = api.ParseLyrics(export.Synced.PlainText()), export.Synced.Source
+    } else {
+        http.Error(w, lyricsNotAvailable, http.StatusNotFound)
+        return
+    }
+
+    if masker := profanityMasker(r); masker != nil {
+        analysis := masker.MaskAnalysis(api.AnalyzeLyrics(export.Lyrics))
+        export.Analysis = &analysis
+        export.Lyrics, _ = masker.MaskLyrics(export.Lyrics)
+        if export.Synced != nil {
+            timed := masker.MaskTimed(*export.Synced)
+            export.Synced = &timed
+        }
+    }
+
+    body, err := export.Render(format)
+    if err == api.ErrNoSyncedLyrics {
+        http.Error(w, "No synced lyrics for this song", http.StatusNotFound)
+        return
+    }
+    if err != nil {
+        log.Printf("Error rendering lyrics download: %v", err)
+        http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", contentType)
+    w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": export.Filename(format)}))
+    w.Write([]byte(body))
+}
+
+// HandleLyricsAnalysis returns the text statistics of a song's lyrics as
+// JSON. The song is given by title and artist, as on /lyrics.
+func HandleLyricsAnalysis(w http.ResponseWriter, r *http.Request) {
+    title := r.URL.Query().Get("title")
+    artist := r.URL.Query().Get("artist")
+
+    result, err := api.FetchLyrics(r.Context(), title, artist)
+    if err != nil {
+        http.Error(w, lyricsNotAvailable, http.StatusNotFound)
         return
     }
 
-    w.Header().Set("Content-Type", "text/plain")
-    w.Write([]byte(lyrics))
+    analysis := api.AnalyzeLyrics(result.Parsed)
+    if masker := profanityMasker(r); masker != nil {
+        analysis = masker.MaskAnalysis(analysis)
+    }
+
+    data := struct {
+        Title    string             `json:"title"`
+        Artist   string             `json:"artist"`
+        Analysis api.LyricsAnalysis `json:"analysis"`
+        Language string             `json:"language,omitempty"`
+    }{
+        Title:    title,
+        Artist:   artist,
+        Analysis: analysis,
+        Language: api.DetectLyricsLanguage(result.Parsed),
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    if err := json.NewEncoder(w).Encode(data); err != nil {
+        log.Printf("Error encoding lyrics analysis: %v", err)
+    }
 }
 
 func HandlePlaylistLyrics(w http.ResponseWriter, r *http.Request) {
@@ -118,10 +376,19 @@ func HandlePlaylistLyrics(w http.ResponseWriter, r *http.Request) {
         pageNum = 1
     }
 
-    lyrics, err := api.FetchLyricsOvh(songTitle, artist)
-    if err != nil {
-        log.Printf("Lyrics fetch error: %v", err)
-        lyrics = "Lyrics not available for this song"
+    lyrics, matchedQuery := fetchLyricsForPage(r.Context(), songTitle, artist)
+    var mood *api.Mood
+    var language *api.Language
+    if lyrics.Raw != lyricsNotAvailable {
+        m := api.AnalyzeMood(lyrics)
+        mood = &m
+        language = lyricsLanguage(lyrics)
+    }
+
+    masker := profanityMasker(r)
+    masked := 0
+    if masker != nil {
+        lyrics, masked = masker.MaskLyrics(lyrics)
     }
 
     spotifyURL := fmt.Sprintf("https://open.spotify.com/track/%s", songID)
@@ -151,6 +418,7 @@ func HandlePlaylistLyrics(w http.ResponseWriter, r *http.Request) {
         Title                string
         Artist               string
         Lyrics               string
+        Parsed               api.ParsedLyrics
         SpotifyURL           string
         InPlaylist           bool
         Query                string
@@ -158,11 +426,17 @@ func HandlePlaylistLyrics(w http.ResponseWriter, r *http.Request) {
         CoverURL             string
         FormattedReleaseDate string
         FormattedDuration    string
+        MatchedQuery         *api.LyricsQuery
+        Mood                 *api.Mood
+        Language             *api.Language
+        Masking              bool
+        MaskedWords          int
     }{
         ID:                   songID,
         Title:                songTitle,
         Artist:               artist,
-        Lyrics:               lyrics,
+        Lyrics:               lyrics.Raw,
+        Parsed:               lyrics,
         SpotifyURL:           spotifyURL,
         InPlaylist:           inPlaylist,
         Query:                query,
@@ -170,6 +444,11 @@ func HandlePlaylistLyrics(w http.ResponseWriter, r *http.Request) {
         CoverURL:             coverURL,
         FormattedReleaseDate: releaseDate,
         FormattedDuration:    duration,
+        MatchedQuery:         matchedQuery,
+        Mood:                 mood,
+        Language:             language,
+        Masking:              masker != nil,
+        MaskedWords:          masked,
     }
 
     if err := PlaylistLyricsTemplate.Execute(w, data); err != nil {
@@ -177,4 +456,40 @@ func HandlePlaylistLyrics(w http.ResponseWriter, r *http.Request) {
         http.Error(w, "Error rendering playlist-lyrics", http.StatusInternalServerError)
         return
     }
-}
\ No newline at end of file
+}
+
+// fetchLyricsForPage looks up lyrics for display. The returned query is
+// only set when the lyrics were found under a different title or artist
+// than the one shown, so the page can say what matched.
+func fetchLyricsForPage(ctx context.Context, title, artist string) (api.ParsedLyrics, *api.LyricsQuery) {
+    result, err := api.FetchLyrics(ctx, title, artist)
+    if err != nil {
+        log.Printf("Lyrics fetch error: %v", err)
+        return api.ParseLyrics(lyricsNotAvailable), nil
+    }
+    if result.Query.Title == title && result.Query.Artist == artist {
+        return result.Parsed, nil
+    }
+    return result.Parsed, &result.Query
+}
+
+// rememberLyrics adds lyrics shown on a lyrics page to the lyrics index, so
+// searching by lyrics finds the song later.
+func rememberLyrics(song api.Song, lyrics api.ParsedLyrics) {
+    if song.ID == "" || lyrics.Raw == lyricsNotAvailable || !api.NeedsLyricsIndex(song) {
+        return
+    }
+    if err := api.IndexLyrics(song, &lyrics); err != nil {
+        log.Printf("Error indexing lyrics for %s: %v", song.ID, err)
+    }
+}
+
+// lyricsLanguage detects the language of lyrics for the language badge. It
+// returns nil when the language is unclear.
+func lyricsLanguage(lyrics api.ParsedLyrics) *api.Language {
+    code := api.DetectLyricsLanguage(lyrics)
+    if code == "" {
+        return nil
+    }
+    return &api.Language{Code: code, Name: api.LanguageName(code)}
+}
@@ -89,22 +89,40 @@ func HandleSearch(w http.ResponseWriter, r *http.Request) {
         pageNum = 1
     }
 
-    filters := api.SearchFilters{
-        StartDate:   r.URL.Query().Get("startDate"),
-        EndDate:     r.URL.Query().Get("endDate"),
-        SortBy:      r.URL.Query().Get("sortBy"),
-        SortOrder:   r.URL.Query().Get("sortOrder"),
-        MinDuration: api.ParseDuration(r.URL.Query().Get("minDuration")),
-        MaxDuration: api.ParseDuration(r.URL.Query().Get("maxDuration")),
-        LyricsFilter: r.URL.Query().Get("lyricsFilter"),
-        PlaylistFilter: r.URL.Query().Get("playlistFilter"),
-    }
-
-    songs, totalResults, err := api.SearchSpotifySongs(query, pageNum, filters)
-    if err != nil {
-        log.Printf("Search error: %v", err)
-        http.Error(w, "Error searching songs", http.StatusInternalServerError)
-        return
+    filters := parseSearchFilters(r)
+    mode := r.URL.Query().Get("mode")
+
+    var songs []api.Song
+    var totalResults int
+    var matches map[string]api.LyricsCandidate
+    if mode == "lyrics" {
+        // The query is a remembered lyric fragment; every candidate that
+        // passes the filters comes on one page, with the lines it matched
+        // when they are known. Without a sort they stay in relevance order.
+        candidates, err := api.FindSongsByLyrics(r.Context(), query)
+        if err != nil {
+            log.Printf("Lyrics search error: %v", err)
+        }
+        matches = make(map[string]api.LyricsCandidate)
+        masker := profanityMasker(r)
+        for _, candidate := range api.FilterLyricsCandidates(candidates, filters) {
+            if masker != nil {
+                candidate.LyricsMatch = masker.MaskMatch(candidate.LyricsMatch)
+            }
+            songs = append(songs, candidate.Song)
+            matches[candidate.Song.ID] = candidate
+        }
+        api.SortSongs(songs, filters.SortBy, filters.SortOrder)
+        totalResults = len(songs)
+        pageNum = 1
+    } else {
+        var err error
+        songs, totalResults, err = api.SearchSpotifySongs(query, pageNum, filters)
+        if err != nil {
+            log.Printf("Search error: %v", err)
+            http.Error(w, "Error searching songs", http.StatusInternalServerError)
+            return
+        }
     }
 
     if filters.PlaylistFilter == "in_playlist" || filters.PlaylistFilter == "not_in_playlist" {
@@ -147,22 +165,30 @@ func HandleSearch(w http.ResponseWriter, r *http.Request) {
 
     data := struct {
         Songs        []api.Song
+        Groups       []api.VersionGroup
         Query        string
+        Mode         string
+        Matches      map[string]api.LyricsCandidate
         CurrentPage  int
         TotalPages   int
         TotalResults int
         ResultsPerPage int
         Filters      api.SearchFilters
+        Languages    []api.Language
         DurationMinutes func(int) int
         DurationSeconds func(int) int
     }{
         Songs:        songs,
+        Groups:       api.GroupVersions(songs),
         Query:        query,
+        Mode:         mode,
+        Matches:      matches,
         CurrentPage:  pageNum,
         TotalPages:   totalPages,
         TotalResults: totalResults,
         ResultsPerPage: resultsPerPage,
         Filters:      filters,
+        Languages:    api.Languages(),
         DurationMinutes: api.DurationMinutes,
         DurationSeconds: api.DurationSeconds,
     }
@@ -172,4 +198,19 @@ func HandleSearch(w http.ResponseWriter, r *http.Request) {
         http.Error(w, "Error rendering results", http.StatusInternalServerError)
         return
     }
-}
\ No newline at end of file
+}
+
+func parseSearchFilters(r *http.Request) api.SearchFilters {
+    return api.SearchFilters{
+        StartDate:   r.URL.Query().Get("startDate"),
+        EndDate:     r.URL.Query().Get("endDate"),
+        SortBy:      r.URL.Query().Get("sortBy"),
+        SortOrder:   r.URL.Query().Get("sortOrder"),
+        MinDuration: api.ParseDuration(r.URL.Query().Get("minDuration")),
+        MaxDuration: api.ParseDuration(r.URL.Query().Get("maxDuration")),
+        LyricsFilter: r.URL.Query().Get("lyricsFilter"),
+        PlaylistFilter: r.URL.Query().Get("playlistFilter"),
+        Mood:        r.URL.Query().Get("mood"),
+        Language:    r.URL.Query().Get("language"),
+    }
+}
@@ -6,13 +6,17 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"harmonify/src/api"
+	playlistpkg "harmonify/src/playlist"
 )
 
 
+const PlaylistPageSize = 20
+
 func HandlePlaylist(w http.ResponseWriter, r *http.Request) {
     playlist, err := LoadPlaylistFromFile(r)
     if err != nil {
@@ -21,10 +25,117 @@ func HandlePlaylist(w http.ResponseWriter, r *http.Request) {
         return
     }
 
+    filters := parseSearchFilters(r)
+    text := r.URL.Query().Get("q")
+    mode := r.URL.Query().Get("mode")
+
+    // In lyrics mode the text is a phrase to find in the lyrics instead of
+    // a title or artist, and each result shows the lines it matched.
+    // Songs are indexed when they are added; a lyrics search or a lyrics,
+    // mood or language filter also picks up songs the index has not got
+    // to yet, such as songs saved before moods and languages existed, so
+    // a plain page load never fetches lyrics.
+    var filtered []api.Song
+    var lyricsMatches map[string]api.LyricsMatch
+    var matchSources map[string]string
+    indexing := 0
+    if mode == "lyrics" || filters.LyricsFilter != "" || filters.Mood != "" || filters.Language != "" {
+        indexing = playlistpkg.IndexLyrics(currentPlaylistID(r), playlist)
+    }
+    if mode == "lyrics" && strings.TrimSpace(text) != "" {
+        var searched []api.Song
+        searched, matchSources = lyricsSearchSongs(r, playlist)
+        matches := api.SearchLyrics(api.FilterSongs(searched, "", filters), text)
+        if filters.SortBy == "" {
+            api.SortByMatches(matches)
+        }
+        lyricsMatches = make(map[string]api.LyricsMatch)
+        masker := profanityMasker(r)
+        for _, match := range matches {
+            if masker != nil {
+                match = masker.MaskMatch(match)
+            }
+            filtered = append(filtered, match.Song)
+            lyricsMatches[match.Song.ID] = match
+        }
+    } else {
+        filtered = api.FilterSongs(playlist, text, filters)
+    }
+
+    pageNum, err := strconv.Atoi(r.URL.Query().Get("page"))
+    if err != nil || pageNum < 1 {
+        pageNum = 1
+    }
+    totalPages := (len(filtered) + PlaylistPageSize - 1) / PlaylistPageSize
+    if totalPages < 1 {
+        totalPages = 1
+    }
+    if pageNum > totalPages {
+        pageNum = totalPages
+    }
+    start := (pageNum - 1) * PlaylistPageSize
+    end := start + PlaylistPageSize
+    if end > len(filtered) {
+        end = len(filtered)
+    }
+
+    var prevURL, nextURL string
+    if pageNum > 1 {
+        prevURL = playlistPageURL(r, pageNum-1)
+    }
+    if pageNum < totalPages {
+        nextURL = playlistPageURL(r, pageNum+1)
+    }
+
+    var shares []playlistpkg.Share
+    _, username, loggedIn := getSessionInfo(r)
+    if loggedIn {
+        shares, err = playlistpkg.ActiveShares(username, playlistpkg.UserPlaylistID(username))
+        if err != nil {
+            log.Printf("Error loading share links: %v", err)
+        }
+    }
+
     data := struct {
-        Playlist []api.Song
+        Playlist     []api.Song
+        LoggedIn     bool
+        Shares       []playlistpkg.Share
+        ShareBaseURL string
+        Text         string
+        Mode         string
+        LyricsMatches map[string]api.LyricsMatch
+        MatchSources map[string]string
+        Indexing     int
+        Filters      api.SearchFilters
+        Moods        []string
+        Languages    []api.Language
+        TotalSongs   int
+        TotalResults int
+        CurrentPage  int
+        TotalPages   int
+        PrevURL      string
+        NextURL      string
+        ShuffleSeed  string
     }{
-        Playlist: playlist,
+        Playlist:     filtered[start:end],
+        LoggedIn:     loggedIn,
+        Shares:       shares,
+        ShareBaseURL: absoluteURL(r, "/shared/"),
+        Text:         text,
+        Mode:         mode,
+        LyricsMatches: lyricsMatches,
+        MatchSources: matchSources,
+        Indexing:     indexing,
+        Filters:      filters,
+        Moods:        api.Moods,
+        Languages:    api.Languages(),
+        TotalSongs:   len(playlist),
+        TotalResults: len(filtered),
+        CurrentPage:  pageNum,
+        TotalPages:   totalPages,
+        PrevURL:      prevURL,
+        NextURL:      nextURL,
+        ShuffleSeed:  r.URL.Query().Get("seed"),
     }
 
     if err := PlaylistTemplate.Execute(w, data); err != nil {
@@ -33,12 +144,75 @@ func HandlePlaylist(w http.ResponseWriter, r *http.Request) {
     }
 }
 
+// lyricsSearchSongs returns the songs a lyrics search on /playlist looks
+// through: the current playlist and, for a logged-in user, every other
+// playlist they can view. The sources name the playlist of each song that
+// is not in the current one.
+func lyricsSearchSongs(r *http.Request, current []api.Song) ([]api.Song, map[string]string) {
+    songs := append([]api.Song(nil), current...)
+    sources := make(map[string]string)
+    seen := make(map[string]bool)
+    for _, song := range current {
+        seen[song.ID] = true
+    }
+
+    _, username, loggedIn := getSessionInfo(r)
+    if !loggedIn {
+        return songs, sources
+    }
+    accessible, err := playlistpkg.Accessible(username)
+    if err != nil {
+        log.Printf("Error listing playlists of %s: %v", username, err)
+        return songs, sources
+    }
+    for _, meta := range accessible {
+        if meta.ID == currentPlaylistID(r) {
+            continue
+        }
+        other, err := playlistpkg.Load(meta.ID)
+        if err != nil {
+            log.Printf("Error loading playlist %s: %v", meta.ID, err)
+            continue
+        }
+        for _, song := range other {
+            if !seen[song.ID] {
+                seen[song.ID] = true
+                songs = append(songs, song)
+                sources[song.ID] = meta.Name
+            }
+        }
+    }
+    return songs, sources
+}
+
+func playlistPageURL(r *http.Request, page int) string {
+    params := r.URL.Query()
+    params.Set("page", strconv.Itoa(page))
+    params.Del("action")
+    return "/playlist?" + params.Encode()
+}
+
 func HandleAddToPlaylist(w http.ResponseWriter, r *http.Request) {
     songId := r.URL.Query().Get("id")
     title := r.URL.Query().Get("title")
     artist := r.URL.Query().Get("artist")
     query := r.URL.Query().Get("query")
     page := r.URL.Query().Get("page")
+    target := r.URL.Query().Get("playlist")
+    _, username, _ := getSessionInfo(r)
+
+    if target != "" {
+        handleAddToCollaborative(w, r, target, username, songId, title, artist)
+        return
+    }
+
+    current, err := LoadPlaylistFromFile(r)
+    if err != nil {
+        log.Printf("Error loading playlist: %v", err)
+        http.Redirect(w, r, fmt.Sprintf("/search?query=%s&page=%s&action=failed", query, page), http.StatusSeeOther)
+        return
+    }
+    Playlist = current
 
     for _, existingSong := range Playlist {
         if strings.EqualFold(existingSong.ID, songId) {
@@ -53,19 +227,56 @@ func HandleAddToPlaylist(w http.ResponseWriter, r *http.Request) {
         }
     }
 
-    accessToken, err := api.GetSpotifyAccessToken()
+    if confirm := versionConfirmation(r, Playlist, api.Song{ID: songId, Title: title, Artist: artist}); confirm != "" {
+        http.Redirect(w, r, fmt.Sprintf("/search?query=%s&page=%s&action=version_exists", query, page)+confirm, http.StatusSeeOther)
+        return
+    }
+
+    fullSong, err := fetchPlaylistSong(songId, title, artist)
     if err != nil {
-        log.Printf("Failed to get Spotify access token: %v", err)
+        log.Printf("Failed to fetch song details: %v", err)
         http.Redirect(w, r, fmt.Sprintf("/search?query=%s&page=%s&action=failed", query, page), http.StatusSeeOther)
         return
     }
+    fullSong.AddedBy = username
 
-    req, err := http.NewRequest("GET", fmt.Sprintf("https://api.spotify.com/v1/tracks/%s", songId), nil)
+    updated, err := playlistpkg.AddSong(currentPlaylistID(r), actorName(r), fullSong)
     if err != nil {
-        log.Printf("Failed to create Spotify request: %v", err)
+        log.Printf("Failed to save playlist: %v", err)
         http.Redirect(w, r, fmt.Sprintf("/search?query=%s&page=%s&action=failed", query, page), http.StatusSeeOther)
         return
     }
+    Playlist = updated
+    http.Redirect(w, r, fmt.Sprintf("/search?query=%s&page=%s&action=added", query, page), http.StatusSeeOther)
+}
+
+// versionConfirmation checks, before song is added, whether songs already
+// has another version of it. If so it returns redirect parameters naming
+// that version and the add URL to follow once the user confirms; it
+// returns "" when there is none or the user has already confirmed.
+func versionConfirmation(r *http.Request, songs []api.Song, song api.Song) string {
+    params := r.URL.Query()
+    if params.Get("confirm_version") != "" {
+        return ""
+    }
+    others := api.OtherVersions(songs, song)
+    if len(others) == 0 {
+        return ""
+    }
+    params.Set("confirm_version", "1")
+    return "&version_of=" + url.QueryEscape(others[0].Title) + "&add=" + url.QueryEscape("/add-to-playlist?"+params.Encode())
+}
+
+func fetchPlaylistSong(songId, title, artist string) (api.Song, error) {
+    accessToken, err := api.GetSpotifyAccessToken()
+    if err != nil {
+        return api.Song{}, fmt.Errorf("failed to get Spotify access token: %v", err)
+    }
+
+    req, err := http.NewRequest("GET", fmt.Sprintf("https://api.spotify.com/v1/tracks/%s", songId), nil)
+    if err != nil {
+        return api.Song{}, fmt.Errorf("failed to create Spotify request: %v", err)
+    }
 
     req.Header.Add("Authorization", "Bearer "+accessToken)
     req.Header.Add("Content-Type", "application/json")
@@ -73,16 +284,12 @@ func HandleAddToPlaylist(w http.ResponseWriter, r *http.Request) {
     client := &http.Client{Timeout: 10 * time.Second}
     resp, err := client.Do(req)
     if err != nil {
-        log.Printf("Failed to fetch song details from Spotify: %v", err)
-        http.Redirect(w, r, fmt.Sprintf("/search?query=%s&page=%s&action=failed", query, page), http.StatusSeeOther)
-        return
+        return api.Song{}, fmt.Errorf("failed to fetch song details from Spotify: %v", err)
     }
     defer resp.Body.Close()
 
     if resp.StatusCode != http.StatusOK {
-        log.Printf("Spotify API returned non-200 status: %v", resp.StatusCode)
-        http.Redirect(w, r, fmt.Sprintf("/search?query=%s&page=%s&action=failed", query, page), http.StatusSeeOther)
-        return
+        return api.Song{}, fmt.Errorf("Spotify API returned non-200 status: %v", resp.StatusCode)
     }
 
     var trackDetails struct {
@@ -92,6 +299,7 @@ func HandleAddToPlaylist(w http.ResponseWriter, r *http.Request) {
             Name string `json:"name"`
         } `json:"artists"`
         Album struct {
+            Name   string `json:"name"`
             Images []struct {
                 URL string `json:"url"`
             } `json:"images"`
@@ -100,18 +308,18 @@ func HandleAddToPlaylist(w http.ResponseWriter, r *http.Request) {
     }
 
     if err := json.NewDecoder(resp.Body).Decode(&trackDetails); err != nil {
-        log.Printf("Failed to decode Spotify response: %v", err)
-        http.Redirect(w, r, fmt.Sprintf("/search?query=%s&page=%s&action=failed", query, page), http.StatusSeeOther)
-        return
+        return api.Song{}, fmt.Errorf("failed to decode Spotify response: %v", err)
     }
 
     fullSong := api.Song{
         ID:          songId,
         Title:       title,
         Artist:      artist,
+        Album:       trackDetails.Album.Name,
         CoverURL:    "",
         ReleaseDate: time.Time{},
         Duration:    trackDetails.Duration,
+        AddedAt:     time.Now(),
     }
 
     if len(trackDetails.Album.Images) > 0 {
@@ -121,31 +329,23 @@ func HandleAddToPlaylist(w http.ResponseWriter, r *http.Request) {
     if trackDetails.Album.ReleaseDate != "" {
         fullSong.ReleaseDate = api.FormatReleaseDate(trackDetails.Album.ReleaseDate)
     }
-    Playlist = append(Playlist, fullSong)
-    if err := SavePlaylistToFile(r); err != nil {
-        log.Printf("Failed to save playlist: %v", err)
-        http.Redirect(w, r, fmt.Sprintf("/search?query=%s&page=%s&action=failed", query, page), http.StatusSeeOther)
-        return
-    }
-    http.Redirect(w, r, fmt.Sprintf("/search?query=%s&page=%s&action=added", query, page), http.StatusSeeOther)
+    return fullSong, nil
 }
 
 func HandleRemoveFromPlaylist(w http.ResponseWriter, r *http.Request) {
     songId := r.URL.Query().Get("id")
 
-    for i, song := range Playlist {
-        if song.ID == songId {
-            Playlist = append(Playlist[:i], Playlist[i+1:]...)
-            if err := SavePlaylistToFile(r); err != nil {
-                log.Printf("Failed to save playlist: %v", err)
-                http.Redirect(w, r, "/playlist?action=failed", http.StatusSeeOther)
-                return
-            }
-
-            http.Redirect(w, r, "/playlist?action=removed", http.StatusSeeOther)
-            return
-        }
+    updated, err := playlistpkg.RemoveSong(currentPlaylistID(r), actorName(r), songId)
+    if err == playlistpkg.ErrSongNotFound {
+        http.Redirect(w, r, "/playlist?action=not_found", http.StatusSeeOther)
+        return
+    }
+    if err != nil {
+        log.Printf("Failed to save playlist: %v", err)
+        http.Redirect(w, r, "/playlist?action=failed", http.StatusSeeOther)
+        return
     }
 
-    http.Redirect(w, r, "/playlist?action=not_found", http.StatusSeeOther)
-}
\ No newline at end of file
+    Playlist = updated
+    http.Redirect(w, r, "/playlist?action=removed", http.StatusSeeOther)
+}
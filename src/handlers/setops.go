@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"harmonify/src/api"
+	"harmonify/src/playlist"
+)
+
+var setOpNames = map[playlist.SetOp]string{
+	playlist.OpUnion:     "%s + %s",
+	playlist.OpIntersect: "%s & %s",
+	playlist.OpDiff:      "%s - %s",
+}
+
+// HandleCombinePlaylists creates a new playlist from the union,
+// intersection or difference of two playlists the user can view.
+func HandleCombinePlaylists(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	_, username, _ := getSessionInfo(r)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	op := playlist.SetOp(r.FormValue("op"))
+	format, ok := setOpNames[op]
+	if !ok {
+		http.Error(w, playlist.ErrInvalidOp.Error(), http.StatusBadRequest)
+		return
+	}
+
+	left, right := r.FormValue("left"), r.FormValue("right")
+	var lists [2][]api.Song
+	for i, id := range []string{left, right} {
+		if !playlist.RoleFor(id, username).CanView() {
+			http.Error(w, playlist.ErrForbidden.Error(), http.StatusForbidden)
+			return
+		}
+		songs, err := playlist.Load(id)
+		if err != nil {
+			log.Printf("Error loading playlist %s: %v", id, err)
+			http.Redirect(w, r, "/playlists?action=failed", http.StatusSeeOther)
+			return
+		}
+		lists[i] = songs
+	}
+
+	songs, err := playlist.Combine(op, lists[0], lists[1])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		name = fmt.Sprintf(format, playlistName(username, left), playlistName(username, right))
+	}
+
+	meta, err := playlist.CreateCollaborative(username, name)
+	if err == nil {
+		_, err = playlist.Update(meta.ID, username, playlist.EventImport, func(existing []api.Song) ([]api.Song, error) {
+			return append(existing, songs...), nil
+		})
+	}
+	if err != nil {
+		log.Printf("Failed to save combined playlist: %v", err)
+		http.Redirect(w, r, "/playlists?action=failed", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, collabURL(meta.ID, "created"), http.StatusSeeOther)
+}
+
+// HandleDedupePlaylist collapses duplicate recordings in a playlist the
+// visitor can edit. An empty id means the visitor's own playlist.
+func HandleDedupePlaylist(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	requested := r.FormValue("id")
+	id, ok := editablePlaylistID(r, requested)
+	if !ok {
+		http.Error(w, playlist.ErrForbidden.Error(), http.StatusForbidden)
+		return
+	}
+
+	result := "deduped"
+	removed, err := playlist.DedupePlaylist(id, actorName(r))
+	if err != nil {
+		log.Printf("Failed to dedupe playlist %s: %v", id, err)
+		result = "failed"
+	} else if id == currentPlaylistID(r) {
+		if songs, err := playlist.Load(id); err == nil {
+			Playlist = songs
+		}
+	}
+
+	suffix := fmt.Sprintf("&count=%d", len(removed))
+	if requested != "" && requested != currentPlaylistID(r) {
+		http.Redirect(w, r, collabURL(id, result)+suffix, http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/playlist?action="+result+suffix, http.StatusSeeOther)
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"harmonify/src/api"
+	"harmonify/src/auth"
+)
+
+// HandleSettings shows and saves the settings of the logged-in user.
+func HandleSettings(w http.ResponseWriter, r *http.Request) {
+	_, username, _ := getSessionInfo(r)
+
+	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Error parsing form", http.StatusBadRequest)
+			return
+		}
+
+		settings := auth.Settings{MaskProfanity: r.FormValue("mask_profanity") == "on"}
+		seen := make(map[string]bool)
+		for _, word := range strings.FieldsFunc(r.FormValue("profanity_words"), func(r rune) bool {
+			return r == ',' || r == '\n' || r == '\r'
+		}) {
+			word = strings.ToLower(strings.TrimSpace(word))
+			if word != "" && !seen[word] {
+				seen[word] = true
+				settings.ProfanityWords = append(settings.ProfanityWords, word)
+			}
+		}
+
+		if err := auth.SaveUserSettings(username, settings); err != nil {
+			log.Printf("Error saving settings for %s: %v", username, err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/settings?saved=1", http.StatusSeeOther)
+		return
+	}
+
+	data := struct {
+		Settings auth.Settings
+		Saved    bool
+	}{
+		Settings: auth.UserSettings(username),
+		Saved:    r.URL.Query().Get("saved") != "",
+	}
+
+	if err := SettingsTemplate.Execute(w, data); err != nil {
+		log.Printf("Error rendering settings template: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// profanityMasker returns the masker for the logged-in user when they have
+// turned profanity masking on, and nil otherwise.
+func profanityMasker(r *http.Request) *api.ProfanityMasker {
+	_, username, loggedIn := getSessionInfo(r)
+	if !loggedIn {
+		return nil
+	}
+	settings := auth.UserSettings(username)
+	if !settings.MaskProfanity {
+		return nil
+	}
+	return api.NewProfanityMasker(settings.ProfanityWords)
+}
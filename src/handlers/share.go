@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"harmonify/src/api"
+	"harmonify/src/playlist"
+)
+
+func HandleCreateShare(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	_, username, _ := getSessionInfo(r)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	var ttl time.Duration
+	if days, err := strconv.Atoi(r.FormValue("expires_days")); err == nil && days > 0 {
+		ttl = time.Duration(days) * 24 * time.Hour
+	}
+
+	if _, err := playlist.CreateShare(username, playlist.UserPlaylistID(username), ttl); err != nil {
+		log.Printf("Failed to create share link: %v", err)
+		http.Redirect(w, r, "/playlist?action=failed", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/playlist?action=shared", http.StatusSeeOther)
+}
+
+func HandleRevokeShare(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	_, username, _ := getSessionInfo(r)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	if err := playlist.RevokeShare(username, r.FormValue("token")); err != nil {
+		log.Printf("Failed to revoke share link: %v", err)
+		http.Redirect(w, r, "/playlist?action=failed", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/playlist?action=revoked", http.StatusSeeOther)
+}
+
+func HandleShared(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.URL.Path, "/shared/")
+	asJSON := strings.HasSuffix(token, ".json")
+	token = strings.TrimSuffix(token, ".json")
+
+	share, err := playlist.GetShare(token)
+	switch err {
+	case nil:
+	case playlist.ErrShareNotFound:
+		http.NotFound(w, r)
+		return
+	case playlist.ErrShareRevoked, playlist.ErrShareExpired:
+		http.Error(w, err.Error(), http.StatusGone)
+		return
+	default:
+		log.Printf("Error loading share link: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	songs, err := playlist.Load(share.PlaylistID)
+	if err != nil {
+		log.Printf("Error loading shared playlist: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// A seed in the link smart-shuffles the view, so everyone opening the
+	// same link sees the same order.
+	seed := r.URL.Query().Get("seed")
+	pagePath := "/shared/" + share.Token
+	if seed != "" {
+		songs = playlist.SmartShuffle(songs, shuffleSeed(seed))
+		pagePath += "?seed=" + url.QueryEscape(seed)
+	}
+
+	if asJSON {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Owner     string     `json:"owner"`
+			CreatedAt time.Time  `json:"created_at"`
+			ExpiresAt time.Time  `json:"expires_at,omitempty"`
+			Seed      string     `json:"seed,omitempty"`
+			Songs     []api.Song `json:"songs"`
+		}{
+			Owner:     share.Owner,
+			CreatedAt: share.CreatedAt,
+			ExpiresAt: share.ExpiresAt,
+			Seed:      seed,
+			Songs:     songs,
+		})
+		return
+	}
+
+	coverURL := ""
+	for _, song := range songs {
+		if song.CoverURL != "" {
+			coverURL = song.CoverURL
+			break
+		}
+	}
+
+	data := struct {
+		Title       string
+		Description string
+		PageURL     string
+		CoverURL    string
+		Token       string
+		Seed        string
+		NextSeed    int64
+		Playlist    []api.Song
+	}{
+		Title:       fmt.Sprintf("%s's playlist on Harmonify", share.Owner),
+		Description: fmt.Sprintf("%d songs shared by %s", len(songs), share.Owner),
+		PageURL:     absoluteURL(r, pagePath),
+		CoverURL:    coverURL,
+		Token:       share.Token,
+		Seed:        seed,
+		NextSeed:    shuffleSeed(""),
+		Playlist:    songs,
+	}
+
+	if err := SharedTemplate.Execute(w, data); err != nil {
+		log.Printf("Error rendering shared template: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+func absoluteURL(r *http.Request, path string) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s%s", scheme, r.Host, path)
+}
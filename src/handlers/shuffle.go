@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"harmonify/src/playlist"
+)
+
+// HandleShufflePlaylist saves a smart-shuffled order for a playlist. The
+// seed is optional; passing the same seed again reproduces the order.
+func HandleShufflePlaylist(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	requested := r.FormValue("id")
+	id, ok := editablePlaylistID(r, requested)
+	if !ok {
+		http.Error(w, playlist.ErrForbidden.Error(), http.StatusForbidden)
+		return
+	}
+
+	seed := shuffleSeed(r.FormValue("seed"))
+	result := "shuffled"
+	songs, err := playlist.Shuffle(id, actorName(r), seed)
+	if err != nil {
+		log.Printf("Failed to shuffle playlist %s: %v", id, err)
+		result = "failed"
+	} else if id == currentPlaylistID(r) {
+		Playlist = songs
+	}
+
+	if requested != "" && requested != currentPlaylistID(r) {
+		http.Redirect(w, r, collabURL(id, result)+"&seed="+strconv.FormatInt(seed, 10), http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/playlist?action="+result+"&seed="+strconv.FormatInt(seed, 10), http.StatusSeeOther)
+}
+
+// shuffleSeed parses a seed from the request, picking a fresh one when it
+// is missing or invalid.
+func shuffleSeed(value string) int64 {
+	if seed, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return seed
+	}
+	return time.Now().UnixNano() % 1000000000
+}
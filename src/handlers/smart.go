@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"harmonify/src/api"
+	"harmonify/src/playlist"
+)
+
+func HandleSmartPlaylists(w http.ResponseWriter, r *http.Request) {
+	_, username, _ := getSessionInfo(r)
+
+	smart, err := playlist.SmartPlaylistsFor(username)
+	if err != nil {
+		log.Printf("Error loading smart playlists: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	sources, err := playlist.Accessible(username)
+	if err != nil {
+		log.Printf("Error loading playlists: %v", err)
+	}
+
+	data := struct {
+		Smart   []playlist.SmartPlaylist
+		Sources []playlist.Meta
+	}{
+		Smart:   smart,
+		Sources: sources,
+	}
+
+	if err := SmartPlaylistsTemplate.Execute(w, data); err != nil {
+		log.Printf("Error rendering smart playlists template: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+func HandleCreateSmartPlaylist(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	_, username, _ := getSessionInfo(r)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	var artists []string
+	for _, artist := range strings.Split(r.FormValue("artists"), ",") {
+		if artist = strings.TrimSpace(artist); artist != "" {
+			artists = append(artists, artist)
+		}
+	}
+	limit, _ := strconv.Atoi(r.FormValue("limit"))
+
+	sp := playlist.SmartPlaylist{
+		Name:  r.FormValue("name"),
+		Owner: username,
+		Rules: playlist.SmartRules{
+			Filters: api.SearchFilters{
+				StartDate:    r.FormValue("startDate"),
+				EndDate:      r.FormValue("endDate"),
+				SortBy:       r.FormValue("sortBy"),
+				SortOrder:    r.FormValue("sortOrder"),
+				MinDuration:  minutesToMillis(r.FormValue("minMinutes")),
+				MaxDuration:  minutesToMillis(r.FormValue("maxMinutes")),
+				LyricsFilter: r.FormValue("lyricsFilter"),
+			},
+			Text:        strings.TrimSpace(r.FormValue("text")),
+			Artists:     artists,
+			ArtistsFrom: r.FormValue("artistsFrom"),
+		},
+		Source:    r.FormValue("source"),
+		LiveQuery: strings.TrimSpace(r.FormValue("liveQuery")),
+		Limit:     limit,
+	}
+
+	for _, id := range []string{sp.Source, sp.Rules.ArtistsFrom} {
+		if id != "" && !playlist.RoleFor(id, username).CanView() {
+			http.Error(w, playlist.ErrForbidden.Error(), http.StatusForbidden)
+			return
+		}
+	}
+
+	created, err := playlist.CreateSmart(sp)
+	if err != nil {
+		log.Printf("Failed to create smart playlist: %v", err)
+		http.Redirect(w, r, "/smart?action=failed", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/smart/view?id="+created.ID+"&action=created", http.StatusSeeOther)
+}
+
+func HandleViewSmartPlaylist(w http.ResponseWriter, r *http.Request) {
+	_, username, _ := getSessionInfo(r)
+
+	sp, err := playlist.GetSmart(username, r.URL.Query().Get("id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	songs, err := sp.Evaluate()
+	evalError := ""
+	if err != nil {
+		log.Printf("Error evaluating smart playlist %s: %v", sp.ID, err)
+		evalError = err.Error()
+	}
+
+	artistsFrom := ""
+	if sp.Rules.ArtistsFrom != "" {
+		artistsFrom = playlistName(username, sp.Rules.ArtistsFrom)
+	}
+	source := "All saved songs"
+	if sp.Source != "" {
+		source = playlistName(username, sp.Source)
+	}
+
+	data := struct {
+		Smart       playlist.SmartPlaylist
+		Playlist    []api.Song
+		Error       string
+		Source      string
+		ArtistsFrom string
+	}{
+		Smart:       sp,
+		Playlist:    songs,
+		Error:       evalError,
+		Source:      source,
+		ArtistsFrom: artistsFrom,
+	}
+
+	if err := SmartPlaylistTemplate.Execute(w, data); err != nil {
+		log.Printf("Error rendering smart playlist template: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+func HandleDeleteSmartPlaylist(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	_, username, _ := getSessionInfo(r)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	if err := playlist.DeleteSmart(username, r.FormValue("id")); err != nil {
+		log.Printf("Failed to delete smart playlist: %v", err)
+		http.Redirect(w, r, "/smart?action=failed", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/smart?action=deleted", http.StatusSeeOther)
+}
+
+func playlistName(username, id string) string {
+	accessible, err := playlist.Accessible(username)
+	if err == nil {
+		for _, meta := range accessible {
+			if meta.ID == id {
+				return meta.Name
+			}
+		}
+	}
+	return id
+}
+
+func minutesToMillis(value string) int {
+	minutes, err := strconv.ParseFloat(value, 64)
+	if err != nil || minutes <= 0 {
+		return 0
+	}
+	return int(minutes * 60 * 1000)
+}
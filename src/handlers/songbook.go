@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"mime"
+	"net/http"
+	"time"
+
+	"harmonify/src/api"
+	"harmonify/src/playlist"
+)
+
+const (
+	// songbookFetchers is how many songs' lyrics a songbook fetches at once.
+	songbookFetchers = 4
+	// songbookFetchBudget bounds the lookups for songs that are not in the
+	// lyrics index, leaving time to write the PDF before the server's write
+	// timeout. Songs not found by then are printed without lyrics.
+	songbookFetchBudget = 6 * time.Second
+)
+
+// HandleSongbook downloads a playlist as a printable PDF songbook, with
+// the lyrics of every song. Without an id it is the visitor's own
+// playlist; otherwise any playlist they can view.
+func HandleSongbook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	_, username, _ := getSessionInfo(r)
+	id := r.URL.Query().Get("id")
+	title := "My Playlist"
+	if id == "" || id == currentPlaylistID(r) {
+		id = currentPlaylistID(r)
+	} else {
+		if !playlist.RoleFor(id, username).CanView() {
+			http.Error(w, playlist.ErrForbidden.Error(), http.StatusForbidden)
+			return
+		}
+		if meta, err := playlist.GetMeta(id); err == nil {
+			title = meta.Name
+		}
+	}
+
+	songs, err := playlist.Load(id)
+	if err != nil {
+		log.Printf("Error loading playlist %s: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	book := api.Songbook{
+		Title:   title,
+		Songs:   make([]api.SongbookSong, len(songs)),
+		Created: time.Now(),
+	}
+
+	// Lyrics come from the index where they can, with their stanzas parsed
+	// again from the stored text; only the songs it has no text for are
+	// looked up, and what is found is indexed for next time.
+	var missing []int
+	for i, song := range songs {
+		book.Songs[i] = api.SongbookSong{Song: song}
+		lyrics, known := api.IndexedLyricsOf(song.ID)
+		if known {
+			book.Songs[i].Lyrics = lyrics
+		} else {
+			missing = append(missing, i)
+		}
+	}
+	if len(missing) > 0 {
+		fetchSongbookLyrics(r.Context(), &book, missing)
+	}
+
+	if masker := profanityMasker(r); masker != nil {
+		for i := range book.Songs {
+			if book.Songs[i].Lyrics != nil {
+				lyrics, _ := masker.MaskLyrics(*book.Songs[i].Lyrics)
+				book.Songs[i].Lyrics = &lyrics
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": book.Filename()}))
+	w.Write(book.PDF())
+}
+
+// fetchSongbookLyrics looks up the lyrics of the songs at the given
+// indexes in book, a few at a time, until songbookFetchBudget runs out.
+func fetchSongbookLyrics(ctx context.Context, book *api.Songbook, indexes []int) {
+	ctx, cancel := context.WithTimeout(ctx, songbookFetchBudget)
+	defer cancel()
+
+	type fetched struct {
+		index  int
+		lyrics *api.ParsedLyrics
+	}
+	results := make(chan fetched, len(indexes))
+	slots := make(chan struct{}, songbookFetchers)
+	for _, i := range indexes {
+		go func(i int, song api.Song) {
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			case <-ctx.Done():
+				results <- fetched{i, nil}
+				return
+			}
+
+			result, err := api.FetchLyrics(ctx, song.Title, song.Artist)
+			if err != nil {
+				results <- fetched{i, nil}
+				return
+			}
+			if err := api.IndexLyrics(song, &result.Parsed); err != nil {
+				log.Printf("Error indexing lyrics for %s: %v", song.ID, err)
+			}
+			results <- fetched{i, &result.Parsed}
+		}(i, book.Songs[i].Song)
+	}
+
+	for range indexes {
+		select {
+		case result := <-results:
+			book.Songs[result.index].Lyrics = result.lyrics
+		case <-ctx.Done():
+			return
+		}
+	}
+}
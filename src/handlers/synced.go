@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"harmonify/src/api"
+)
+
+const maxLRCUploadSize = 256 << 10
+
+// HandleUploadLRC stores an uploaded .lrc file as the synced lyrics for a
+// track. Uploads are shared: every visitor of the track sees them.
+func HandleUploadLRC(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxLRCUploadSize+4096)
+	if err := r.ParseMultipartForm(maxLRCUploadSize); err != nil {
+		http.Error(w, "Upload too large or malformed", http.StatusBadRequest)
+		return
+	}
+
+	songID := r.FormValue("id")
+	back := lyricsPageURL(songID, r.FormValue("title"), r.FormValue("artist"), r.FormValue("query"), r.FormValue("page"))
+
+	file, _, err := r.FormFile("lrc")
+	if err != nil {
+		http.Redirect(w, r, back+"&action=lrc_invalid", http.StatusSeeOther)
+		return
+	}
+	defer file.Close()
+
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		http.Error(w, "Error reading upload", http.StatusBadRequest)
+		return
+	}
+
+	lyrics, err := api.ParseLRC(string(data))
+	if err != nil {
+		http.Redirect(w, r, back+"&action=lrc_invalid", http.StatusSeeOther)
+		return
+	}
+	saveSyncedLyrics(w, r, songID, lyrics, back, "lrc_uploaded", true)
+}
+
+// HandleSaveSync stores the LRC text produced by the tap-along editor on
+// the lyrics page.
+func HandleSaveSync(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxLRCUploadSize)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	songID := r.FormValue("id")
+	back := lyricsPageURL(songID, r.FormValue("title"), r.FormValue("artist"), r.FormValue("query"), r.FormValue("page"))
+
+	lyrics, err := api.ParseLRC(r.FormValue("lrc"))
+	if err != nil {
+		http.Redirect(w, r, back+"&action=lrc_invalid", http.StatusSeeOther)
+		return
+	}
+	lyrics.Tags["ti"] = r.FormValue("title")
+	lyrics.Tags["ar"] = r.FormValue("artist")
+	saveSyncedLyrics(w, r, songID, lyrics, back, "sync_saved", true)
+}
+
+// HandleAdjustSyncOffset shifts the synced lyrics of a track by a number
+// of milliseconds. Lyrics that came from a provider are stored locally
+// with the new offset, so the adjustment is shared like an upload.
+func HandleAdjustSyncOffset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	songID, title, artist := r.FormValue("id"), r.FormValue("title"), r.FormValue("artist")
+	back := lyricsPageURL(songID, title, artist, r.FormValue("query"), r.FormValue("page"))
+
+	offset, err := strconv.Atoi(r.FormValue("offset"))
+	if err != nil {
+		http.Redirect(w, r, back+"&action=failed", http.StatusSeeOther)
+		return
+	}
+
+	lyrics, err := api.FetchSyncedLyrics(r.Context(), songID, title, artist, 0)
+	if err != nil {
+		http.Redirect(w, r, back+"&action=failed", http.StatusSeeOther)
+		return
+	}
+	lyrics.Offset = time.Duration(offset) * time.Millisecond
+	saveSyncedLyrics(w, r, songID, lyrics, back, "offset_saved", false)
+}
+
+// saveSyncedLyrics stores the lyrics and redirects back to the lyrics page
+// with the given action. With credit set, the visitor is recorded as the
+// author of the sync; otherwise the existing credit is kept.
+func saveSyncedLyrics(w http.ResponseWriter, r *http.Request, songID string, lyrics api.TimedLyrics, back, action string, credit bool) {
+	if lyrics.Tags == nil {
+		lyrics.Tags = make(map[string]string)
+	}
+	if _, username, _ := getSessionInfo(r); credit || lyrics.Tags["by"] == "" {
+		lyrics.Tags["by"] = username
+	}
+
+	if err := api.SaveSyncedLyrics(songID, lyrics); err != nil {
+		log.Printf("Failed to save synced lyrics for %s: %v", songID, err)
+		http.Redirect(w, r, back+"&action=failed", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, back+"&action="+action, http.StatusSeeOther)
+}
+
+func lyricsPageURL(id, title, artist, query, page string) string {
+	return fmt.Sprintf("/lyrics?id=%s&title=%s&artist=%s&query=%s&page=%s",
+		url.QueryEscape(id),
+		url.QueryEscape(title),
+		url.QueryEscape(artist),
+		url.QueryEscape(query),
+		url.QueryEscape(page))
+}
@@ -0,0 +1,326 @@
+package playlist
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"harmonify/src/api"
+	"harmonify/src/auth"
+)
+
+type Role string
+
+const (
+	RoleOwner  Role = "owner"
+	RoleEditor Role = "editor"
+	RoleViewer Role = "viewer"
+)
+
+type Member struct {
+	Username string    `json:"username"`
+	Role     Role      `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+type Invite struct {
+	Username  string    `json:"username"`
+	Role      Role      `json:"role"`
+	InvitedBy string    `json:"invited_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Meta struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Owner     string    `json:"owner"`
+	Members   []Member  `json:"members"`
+	Invites   []Invite  `json:"invites"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+var (
+	MetaFile = "data/playlists.json"
+
+	ErrPlaylistNotFound = errors.New("playlist not found")
+	ErrForbidden        = errors.New("you do not have permission for this playlist")
+	ErrUnknownUser      = errors.New("user does not exist")
+	ErrAlreadyMember    = errors.New("user is already a member of this playlist")
+	ErrNotMember        = errors.New("user is not a member of this playlist")
+	ErrInviteNotFound   = errors.New("invite not found")
+	ErrInvalidRole      = errors.New("invalid role")
+
+	metaMu sync.Mutex
+)
+
+func (r Role) CanView() bool {
+	return r == RoleOwner || r == RoleEditor || r == RoleViewer
+}
+
+func (r Role) CanEdit() bool {
+	return r == RoleOwner || r == RoleEditor
+}
+
+func (m Meta) RoleOf(username string) Role {
+	for _, member := range m.Members {
+		if member.Username == username {
+			return member.Role
+		}
+	}
+	return ""
+}
+
+func CreateCollaborative(owner, name string) (Meta, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Untitled playlist"
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return Meta{}, err
+	}
+
+	now := time.Now()
+	meta := Meta{
+		ID:        "collab_" + token[:12],
+		Name:      name,
+		Owner:     owner,
+		Members:   []Member{{Username: owner, Role: RoleOwner, JoinedAt: now}},
+		Invites:   []Invite{},
+		CreatedAt: now,
+	}
+
+	err = updateMetas(func(metas []Meta) ([]Meta, error) {
+		return append(metas, meta), nil
+	})
+	if err != nil {
+		return Meta{}, err
+	}
+	return meta, Save(meta.ID, []api.Song{})
+}
+
+func GetMeta(id string) (Meta, error) {
+	metaMu.Lock()
+	defer metaMu.Unlock()
+
+	metas, err := loadMetas()
+	if err != nil {
+		return Meta{}, err
+	}
+	for _, meta := range metas {
+		if meta.ID == id {
+			return meta, nil
+		}
+	}
+	return Meta{}, ErrPlaylistNotFound
+}
+
+// RoleFor reports what username may do with the playlist id. A user always
+// owns their personal playlist; other playlists are looked up in MetaFile.
+func RoleFor(id, username string) Role {
+	if username == "" {
+		return ""
+	}
+	if id == UserPlaylistID(username) {
+		return RoleOwner
+	}
+	meta, err := GetMeta(id)
+	if err != nil {
+		return ""
+	}
+	return meta.RoleOf(username)
+}
+
+func PlaylistsFor(username string) ([]Meta, error) {
+	metaMu.Lock()
+	defer metaMu.Unlock()
+
+	metas, err := loadMetas()
+	if err != nil {
+		return nil, err
+	}
+
+	var mine []Meta
+	for _, meta := range metas {
+		if meta.RoleOf(username) != "" {
+			mine = append(mine, meta)
+		}
+	}
+	return mine, nil
+}
+
+// Accessible lists every playlist username can view, starting with their
+// personal playlist.
+func Accessible(username string) ([]Meta, error) {
+	personal := Meta{
+		ID:      UserPlaylistID(username),
+		Name:    "My Playlist",
+		Owner:   username,
+		Members: []Member{{Username: username, Role: RoleOwner}},
+	}
+
+	shared, err := PlaylistsFor(username)
+	if err != nil {
+		return nil, err
+	}
+	return append([]Meta{personal}, shared...), nil
+}
+
+func PendingInvites(username string) ([]Meta, error) {
+	metaMu.Lock()
+	defer metaMu.Unlock()
+
+	metas, err := loadMetas()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Meta
+	for _, meta := range metas {
+		for _, invite := range meta.Invites {
+			if invite.Username == username {
+				pending = append(pending, meta)
+				break
+			}
+		}
+	}
+	return pending, nil
+}
+
+func InviteUser(id, inviter, invitee string, role Role) error {
+	if role != RoleEditor && role != RoleViewer {
+		return ErrInvalidRole
+	}
+	if !auth.UserExists(invitee) {
+		return ErrUnknownUser
+	}
+
+	return updateMeta(id, func(meta *Meta) error {
+		if meta.RoleOf(inviter) != RoleOwner {
+			return ErrForbidden
+		}
+		if meta.RoleOf(invitee) != "" {
+			return ErrAlreadyMember
+		}
+
+		for i, invite := range meta.Invites {
+			if invite.Username == invitee {
+				meta.Invites[i].Role = role
+				return nil
+			}
+		}
+		meta.Invites = append(meta.Invites, Invite{
+			Username:  invitee,
+			Role:      role,
+			InvitedBy: inviter,
+			CreatedAt: time.Now(),
+		})
+		return nil
+	})
+}
+
+func RespondToInvite(id, username string, accept bool) error {
+	return updateMeta(id, func(meta *Meta) error {
+		for i, invite := range meta.Invites {
+			if invite.Username != username {
+				continue
+			}
+			meta.Invites = append(meta.Invites[:i], meta.Invites[i+1:]...)
+			if accept {
+				meta.Members = append(meta.Members, Member{
+					Username: username,
+					Role:     invite.Role,
+					JoinedAt: time.Now(),
+				})
+			}
+			return nil
+		}
+		return ErrInviteNotFound
+	})
+}
+
+// RemoveMember lets the owner remove anyone but themselves, and lets any
+// member leave the playlist on their own.
+func RemoveMember(id, actor, username string) error {
+	return updateMeta(id, func(meta *Meta) error {
+		if username == meta.Owner {
+			return ErrForbidden
+		}
+		if actor != username && meta.RoleOf(actor) != RoleOwner {
+			return ErrForbidden
+		}
+
+		for i, member := range meta.Members {
+			if member.Username == username {
+				meta.Members = append(meta.Members[:i], meta.Members[i+1:]...)
+				return nil
+			}
+		}
+		return ErrNotMember
+	})
+}
+
+func updateMeta(id string, fn func(*Meta) error) error {
+	return updateMetas(func(metas []Meta) ([]Meta, error) {
+		for i := range metas {
+			if metas[i].ID == id {
+				if err := fn(&metas[i]); err != nil {
+					return nil, err
+				}
+				return metas, nil
+			}
+		}
+		return nil, ErrPlaylistNotFound
+	})
+}
+
+func updateMetas(fn func([]Meta) ([]Meta, error)) error {
+	metaMu.Lock()
+	defer metaMu.Unlock()
+
+	metas, err := loadMetas()
+	if err != nil {
+		return err
+	}
+
+	metas, err = fn(metas)
+	if err != nil {
+		return err
+	}
+	return saveMetas(metas)
+}
+
+func loadMetas() ([]Meta, error) {
+	if _, err := os.Stat(MetaFile); os.IsNotExist(err) {
+		return []Meta{}, nil
+	}
+
+	data, err := ioutil.ReadFile(MetaFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var metas []Meta
+	if err := json.Unmarshal(data, &metas); err != nil {
+		return nil, err
+	}
+	return metas, nil
+}
+
+func saveMetas(metas []Meta) error {
+	if err := os.MkdirAll(filepath.Dir(MetaFile), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(metas, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(MetaFile, data, 0644)
+}
@@ -0,0 +1,154 @@
+package playlist
+
+import (
+	"math/rand"
+	"strings"
+
+	"harmonify/src/api"
+)
+
+const fitAttempts = 200
+
+type FitOptions struct {
+	TargetMs           int
+	ToleranceMs        int
+	AvoidRepeatArtists bool
+	StartDate          string
+	EndDate            string
+	Seed               int64
+}
+
+type FitResult struct {
+	Songs   []api.Song
+	TotalMs int
+	Fits    bool
+}
+
+func (r FitResult) DiffMs(target int) int {
+	return abs(r.TotalMs - target)
+}
+
+// FitDuration picks songs from candidates whose total length lands as
+// close as possible to opts.TargetMs. It runs a number of randomized greedy
+// passes, each followed by a swap pass, and keeps the best one. The same
+// seed always produces the same result.
+func FitDuration(candidates []api.Song, opts FitOptions) FitResult {
+	filters := api.SearchFilters{StartDate: opts.StartDate, EndDate: opts.EndDate}
+
+	var pool []api.Song
+	for _, song := range candidates {
+		if song.Duration > 0 && api.PassesFilters(song, filters) {
+			pool = append(pool, song)
+		}
+	}
+
+	rng := rand.New(rand.NewSource(opts.Seed))
+	best := FitResult{}
+	bestDiff := -1
+
+	for attempt := 0; attempt < fitAttempts && len(pool) > 0; attempt++ {
+		order := rng.Perm(len(pool))
+		chosen := greedyFill(pool, order, opts)
+		chosen = improveBySwaps(pool, chosen, opts)
+
+		total := 0
+		for _, i := range chosen {
+			total += pool[i].Duration
+		}
+
+		if diff := abs(total - opts.TargetMs); bestDiff < 0 || diff < bestDiff {
+			bestDiff = diff
+			best = FitResult{TotalMs: total, Songs: make([]api.Song, 0, len(chosen))}
+			for _, i := range chosen {
+				best.Songs = append(best.Songs, pool[i])
+			}
+		}
+		if bestDiff <= opts.ToleranceMs {
+			break
+		}
+	}
+
+	best.Fits = bestDiff >= 0 && bestDiff <= opts.ToleranceMs
+	return best
+}
+
+func greedyFill(pool []api.Song, order []int, opts FitOptions) []int {
+	limit := opts.TargetMs + opts.ToleranceMs
+	artists := make(map[string]bool)
+
+	var chosen []int
+	total := 0
+	for _, i := range order {
+		song := pool[i]
+		artist := artistKey(song)
+		if opts.AvoidRepeatArtists && artists[artist] {
+			continue
+		}
+		if total+song.Duration > limit {
+			continue
+		}
+		chosen = append(chosen, i)
+		artists[artist] = true
+		total += song.Duration
+		if total >= opts.TargetMs-opts.ToleranceMs {
+			break
+		}
+	}
+	return chosen
+}
+
+// improveBySwaps replaces chosen songs with unused ones whenever that moves
+// the total closer to the target.
+func improveBySwaps(pool []api.Song, chosen []int, opts FitOptions) []int {
+	used := make(map[int]bool)
+	artists := make(map[string]int)
+	total := 0
+	for _, i := range chosen {
+		used[i] = true
+		artists[artistKey(pool[i])]++
+		total += pool[i].Duration
+	}
+
+	improved := true
+	for improved && abs(total-opts.TargetMs) > opts.ToleranceMs {
+		improved = false
+		for ci, out := range chosen {
+			for in := range pool {
+				if used[in] {
+					continue
+				}
+				if opts.AvoidRepeatArtists && artistKey(pool[in]) != artistKey(pool[out]) && artists[artistKey(pool[in])] > 0 {
+					continue
+				}
+
+				newTotal := total - pool[out].Duration + pool[in].Duration
+				if abs(newTotal-opts.TargetMs) >= abs(total-opts.TargetMs) {
+					continue
+				}
+
+				used[out], used[in] = false, true
+				artists[artistKey(pool[out])]--
+				artists[artistKey(pool[in])]++
+				chosen[ci] = in
+				total = newTotal
+				improved = true
+				break
+			}
+			if improved {
+				break
+			}
+		}
+	}
+	return chosen
+}
+
+func artistKey(song api.Song) string {
+	return strings.ToLower(strings.TrimSpace(song.Artist))
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
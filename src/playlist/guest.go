@@ -0,0 +1,109 @@
+package playlist
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"harmonify/src/api"
+)
+
+const guestPrefix = "guest_"
+
+var GuestPlaylistTTL = 30 * 24 * time.Hour
+
+func GuestPlaylistID(guestID string) string {
+	return guestPrefix + guestID
+}
+
+// MergeInto folds the songs of sourceID into targetID, skipping songs the
+// target already has, and then deletes the source playlist.
+func MergeInto(targetID, sourceID, actor string) (int, error) {
+	source, err := Load(sourceID)
+	if err != nil {
+		return 0, err
+	}
+
+	merged := 0
+	if len(source) > 0 {
+		_, err = Update(targetID, actor, EventImport, func(songs []api.Song) ([]api.Song, error) {
+			existing := make(map[string]bool)
+			for _, song := range songs {
+				existing[strings.ToLower(song.ID)] = true
+			}
+			for _, song := range source {
+				if existing[strings.ToLower(song.ID)] {
+					continue
+				}
+				existing[strings.ToLower(song.ID)] = true
+				songs = append(songs, song)
+				merged++
+			}
+			return songs, nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return merged, Delete(sourceID)
+}
+
+func Delete(id string) error {
+	fileMu.Lock()
+	defer fileMu.Unlock()
+
+	path, err := Path(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	historyFile, _ := historyPath(id)
+	if err := os.Remove(historyFile); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// CleanupGuestPlaylists deletes guest playlists that have not been
+// modified for longer than maxAge and returns how many were removed.
+func CleanupGuestPlaylists(maxAge time.Duration) (int, error) {
+	matches, err := filepath.Glob(filepath.Join(Dir, guestPrefix+"*.json"))
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	cutoff := time.Now().Add(-maxAge)
+	for _, path := range matches {
+		info, err := os.Stat(path)
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		id := strings.TrimSuffix(filepath.Base(path), ".json")
+		if err := Delete(id); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+func StartGuestCleanup(interval time.Duration) {
+	go func() {
+		for {
+			removed, err := CleanupGuestPlaylists(GuestPlaylistTTL)
+			if err != nil {
+				log.Printf("Guest playlist cleanup failed: %v", err)
+			} else if removed > 0 {
+				log.Printf("Removed %d stale guest playlists", removed)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
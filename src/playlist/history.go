@@ -0,0 +1,375 @@
+package playlist
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"harmonify/src/api"
+)
+
+type EventType string
+
+const (
+	EventAdd     EventType = "add"
+	EventRemove  EventType = "remove"
+	EventReorder EventType = "reorder"
+	EventImport  EventType = "import"
+	EventUndo    EventType = "undo"
+	EventRestore EventType = "restore"
+)
+
+const (
+	MaxHistoryEvents = 200
+	TrashRetention   = 30 * 24 * time.Hour
+)
+
+type Event struct {
+	ID      int        `json:"id"`
+	Type    EventType  `json:"type"`
+	Actor   string     `json:"actor"`
+	Time    time.Time  `json:"time"`
+	Added   []api.Song `json:"added,omitempty"`
+	Removed []api.Song `json:"removed,omitempty"`
+	// Order holds the song IDs of the playlist before the change. With
+	// Removed it is enough to rebuild that state from the one after it,
+	// so events stay small however long the playlist is.
+	Order  []string `json:"order"`
+	Undone bool     `json:"undone,omitempty"`
+	// Before is the full playlist before the change, as older history
+	// files stored it. loadHistory turns it into Order.
+	Before []api.Song `json:"before,omitempty"`
+}
+
+type TrashItem struct {
+	Song      api.Song  `json:"song"`
+	RemovedBy string    `json:"removed_by"`
+	RemovedAt time.Time `json:"removed_at"`
+}
+
+type History struct {
+	Events []Event     `json:"events"`
+	Trash  []TrashItem `json:"trash"`
+}
+
+var (
+	ErrNothingToUndo = errors.New("nothing to undo")
+	ErrEventNotFound = errors.New("history entry not found")
+)
+
+func (t TrashItem) ExpiresAt() time.Time {
+	return t.RemovedAt.Add(TrashRetention)
+}
+
+func GetHistory(id string) (History, error) {
+	fileMu.Lock()
+	defer fileMu.Unlock()
+
+	history, err := loadHistory(id)
+	if err != nil {
+		return History{}, err
+	}
+	history.Trash = activeTrash(history.Trash)
+	return history, nil
+}
+
+// Undo reverts the most recent change that has not been undone yet.
+func Undo(id, actor string) ([]api.Song, error) {
+	fileMu.Lock()
+	defer fileMu.Unlock()
+
+	history, err := loadHistory(id)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := load(id)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(history.Events) - 1; i >= 0; i-- {
+		event := history.Events[i]
+		if event.Undone || event.Type == EventUndo {
+			continue
+		}
+
+		before := stateBefore(history.Events, current, i)
+		history.Events[i].Undone = true
+		if err := saveHistory(id, history); err != nil {
+			return nil, err
+		}
+		return replace(id, actor, EventUndo, before)
+	}
+	return nil, ErrNothingToUndo
+}
+
+// RestoreTo puts the playlist back into the state it had right after the
+// event with the given ID. Event 0 means the state before the oldest
+// change still in the history; older changes have been trimmed and can't
+// be restored.
+func RestoreTo(id, actor string, eventID int) ([]api.Song, error) {
+	fileMu.Lock()
+	defer fileMu.Unlock()
+
+	history, err := loadHistory(id)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := load(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if eventID == 0 && len(history.Events) > 0 {
+		return replace(id, actor, EventRestore, stateBefore(history.Events, current, 0))
+	}
+
+	for i, event := range history.Events {
+		if event.ID != eventID {
+			continue
+		}
+		if i == len(history.Events)-1 {
+			return current, nil
+		}
+		return replace(id, actor, EventRestore, stateBefore(history.Events, current, i+1))
+	}
+	return nil, ErrEventNotFound
+}
+
+func RestoreFromTrash(id, actor, songID string) ([]api.Song, error) {
+	fileMu.Lock()
+	defer fileMu.Unlock()
+
+	history, err := loadHistory(id)
+	if err != nil {
+		return nil, err
+	}
+
+	history.Trash = activeTrash(history.Trash)
+	for i, item := range history.Trash {
+		if item.Song.ID != songID {
+			continue
+		}
+
+		songs, err := load(id)
+		if err != nil {
+			return nil, err
+		}
+		for _, song := range songs {
+			if song.ID == songID {
+				return nil, ErrDuplicateSong
+			}
+		}
+
+		history.Trash = append(history.Trash[:i], history.Trash[i+1:]...)
+		if err := saveHistory(id, history); err != nil {
+			return nil, err
+		}
+		return replace(id, actor, EventRestore, append(songs, item.Song))
+	}
+	return nil, ErrSongNotFound
+}
+
+// replace overwrites the playlist with songs and records the change.
+// The caller must hold fileMu.
+func replace(id, actor string, kind EventType, songs []api.Song) ([]api.Song, error) {
+	before, err := load(id)
+	if err != nil {
+		return nil, err
+	}
+
+	after := append([]api.Song{}, songs...)
+	if err := save(id, after); err != nil {
+		return nil, err
+	}
+	if err := record(id, actor, kind, before, after); err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+// record appends an event for the change from before to after and moves
+// any removed songs to the trash. It also queues the change for the lyrics
+// index. The caller must hold fileMu.
+func record(id, actor string, kind EventType, before, after []api.Song) error {
+	history, err := loadHistory(id)
+	if err != nil {
+		return err
+	}
+
+	added, removed := diffSongs(before, after)
+	now := time.Now()
+
+	nextID := 1
+	if n := len(history.Events); n > 0 {
+		nextID = history.Events[n-1].ID + 1
+	}
+	history.Events = append(history.Events, Event{
+		ID:      nextID,
+		Type:    kind,
+		Actor:   actor,
+		Time:    now,
+		Added:   added,
+		Removed: removed,
+		Order:   songIDs(before),
+	})
+	if len(history.Events) > MaxHistoryEvents {
+		history.Events = history.Events[len(history.Events)-MaxHistoryEvents:]
+	}
+
+	for _, song := range removed {
+		history.Trash = append(history.Trash, TrashItem{
+			Song:      song,
+			RemovedBy: actor,
+			RemovedAt: now,
+		})
+	}
+	inAfter := make(map[string]bool)
+	for _, song := range after {
+		inAfter[song.ID] = true
+	}
+	var trash []TrashItem
+	for _, item := range activeTrash(history.Trash) {
+		if !inAfter[item.Song.ID] {
+			trash = append(trash, item)
+		}
+	}
+	history.Trash = trash
+
+	if err := saveHistory(id, history); err != nil {
+		return err
+	}
+	dequeueLyrics(id, removed)
+	go indexLyrics(id, queueLyrics(id, added))
+	return nil
+}
+
+// stateBefore rebuilds the playlist as it was before events[index] by
+// reverting every later change, newest first, starting from current.
+func stateBefore(events []Event, current []api.Song, index int) []api.Song {
+	state := current
+	for i := len(events) - 1; i >= index; i-- {
+		state = events[i].revert(state)
+	}
+	return state
+}
+
+// revert turns the playlist after the event into the one before it. Songs
+// that are still in the playlist keep their current details.
+func (e Event) revert(after []api.Song) []api.Song {
+	songs := make(map[string]api.Song, len(after)+len(e.Removed))
+	for _, song := range e.Removed {
+		songs[song.ID] = song
+	}
+	for _, song := range after {
+		songs[song.ID] = song
+	}
+
+	before := make([]api.Song, 0, len(e.Order))
+	for _, id := range e.Order {
+		if song, ok := songs[id]; ok {
+			before = append(before, song)
+		}
+	}
+	return before
+}
+
+func songIDs(songs []api.Song) []string {
+	ids := make([]string, len(songs))
+	for i, song := range songs {
+		ids[i] = song.ID
+	}
+	return ids
+}
+
+func diffSongs(before, after []api.Song) ([]api.Song, []api.Song) {
+	inBefore := make(map[string]bool)
+	for _, song := range before {
+		inBefore[song.ID] = true
+	}
+	inAfter := make(map[string]bool)
+	for _, song := range after {
+		inAfter[song.ID] = true
+	}
+
+	var added, removed []api.Song
+	for _, song := range after {
+		if !inBefore[song.ID] {
+			added = append(added, song)
+		}
+	}
+	for _, song := range before {
+		if !inAfter[song.ID] {
+			removed = append(removed, song)
+		}
+	}
+	return added, removed
+}
+
+func activeTrash(trash []TrashItem) []TrashItem {
+	cutoff := time.Now().Add(-TrashRetention)
+	active := []TrashItem{}
+	for _, item := range trash {
+		if item.RemovedAt.After(cutoff) {
+			active = append(active, item)
+		}
+	}
+	return active
+}
+
+func historyPath(id string) (string, error) {
+	if _, err := Path(id); err != nil {
+		return "", err
+	}
+	return filepath.Join(Dir, "history", id+".json"), nil
+}
+
+func loadHistory(id string) (History, error) {
+	path, err := historyPath(id)
+	if err != nil {
+		return History{}, err
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return History{Events: []Event{}, Trash: []TrashItem{}}, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return History{}, err
+	}
+
+	var history History
+	if err := json.Unmarshal(data, &history); err != nil {
+		return History{}, err
+	}
+	for i, event := range history.Events {
+		if event.Before != nil {
+			history.Events[i].Order = songIDs(event.Before)
+			history.Events[i].Before = nil
+		}
+	}
+	return history, nil
+}
+
+func saveHistory(id string, history History) error {
+	path, err := historyPath(id)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,334 @@
+package playlist
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"harmonify/src/api"
+)
+
+// TestMain keeps playlists, history and the lyrics index in a temporary
+// directory. Adding songs starts background lyrics indexing, so the paths
+// are not put back before the test binary exits.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "playlist-test")
+	if err != nil {
+		panic(err)
+	}
+	Dir = dir
+	api.LyricsIndexFile = dir + "/lyrics_index.json"
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+var playlistCount int
+
+// newPlaylist returns the ID of an empty playlist no other test uses.
+func newPlaylist(t *testing.T) string {
+	t.Helper()
+	playlistCount++
+	return fmt.Sprintf("history_test_%d", playlistCount)
+}
+
+// testSong returns a song with the given ID, recorded in the lyrics index
+// as having no lyrics so indexing never looks it up.
+func testSong(t *testing.T, id string) api.Song {
+	t.Helper()
+	song := api.Song{ID: id, Title: "Song " + id, Artist: "Artist"}
+	if err := api.IndexLyrics(song, nil); err != nil {
+		t.Fatal(err)
+	}
+	return song
+}
+
+func ids(songs []api.Song) string {
+	return strings.Join(songIDs(songs), " ")
+}
+
+// step is one change to a playlist: "+a" adds a, "-a" removes it and
+// "=c a b" reorders the playlist.
+func apply(t *testing.T, id, step string) {
+	t.Helper()
+	var err error
+	switch step[0] {
+	case '+':
+		_, err = AddSong(id, "alice", testSong(t, step[1:]))
+	case '-':
+		_, err = RemoveSong(id, "alice", step[1:])
+	case '=':
+		order := strings.Fields(step[1:])
+		_, err = Update(id, "alice", EventReorder, func(songs []api.Song) ([]api.Song, error) {
+			byID := make(map[string]api.Song)
+			for _, song := range songs {
+				byID[song.ID] = song
+			}
+			var reordered []api.Song
+			for _, songID := range order {
+				reordered = append(reordered, byID[songID])
+			}
+			return reordered, nil
+		})
+	default:
+		t.Fatalf("unknown step %q", step)
+	}
+	if err != nil {
+		t.Fatalf("step %q: %v", step, err)
+	}
+}
+
+func TestUndo(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []string
+		undos int
+		want  string
+		err   error
+	}{
+		{"undo an add", []string{"+a", "+b"}, 1, "a", nil},
+		{"undo twice", []string{"+a", "+b"}, 2, "", nil},
+		{"undo a removal in place", []string{"+a", "+b", "+c", "-b"}, 1, "a b c", nil},
+		{"undo a reorder", []string{"+a", "+b", "+c", "=c a b"}, 1, "a b c", nil},
+		{"undo past a reorder", []string{"+a", "+b", "=b a", "-a"}, 2, "a b", nil},
+		{"nothing to undo", nil, 1, "", ErrNothingToUndo},
+		{"more undos than changes", []string{"+a"}, 2, "", ErrNothingToUndo},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := newPlaylist(t)
+			for _, step := range tt.steps {
+				apply(t, id, step)
+			}
+			var err error
+			for i := 0; i < tt.undos && err == nil; i++ {
+				_, err = Undo(id, "bob")
+			}
+			if err != tt.err {
+				t.Fatalf("Undo err = %v, want %v", err, tt.err)
+			}
+			songs, _ := Load(id)
+			if got := ids(songs); got != tt.want {
+				t.Errorf("after undo = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUndoKeepsCurrentDetails(t *testing.T) {
+	id := newPlaylist(t)
+	apply(t, id, "+a")
+	apply(t, id, "+b")
+
+	songs, _ := Load(id)
+	songs[0].Language = "es"
+	if err := Save(id, songs); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := Undo(id, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids(after) != "a" || after[0].Language != "es" {
+		t.Errorf("after undo = %+v, want a with its language tag", after)
+	}
+
+	history, _ := GetHistory(id)
+	last := history.Events[len(history.Events)-1]
+	if last.Type != EventUndo || last.Actor != "bob" || !history.Events[1].Undone {
+		t.Errorf("history = %+v, want the add of b undone by bob", history.Events)
+	}
+}
+
+func TestRestoreTo(t *testing.T) {
+	steps := []string{"+a", "+b", "-a", "+c", "=c b"}
+	tests := []struct {
+		event int
+		want  string
+		err   error
+	}{
+		{0, "", nil},
+		{1, "a", nil},
+		{2, "a b", nil},
+		{3, "b", nil},
+		{4, "b c", nil},
+		{5, "c b", nil},
+		{99, "c b", ErrEventNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.event), func(t *testing.T) {
+			id := newPlaylist(t)
+			for _, step := range steps {
+				apply(t, id, step)
+			}
+			restored, err := RestoreTo(id, "bob", tt.event)
+			if err != tt.err {
+				t.Fatalf("RestoreTo err = %v, want %v", err, tt.err)
+			}
+			songs, _ := Load(id)
+			if got := ids(songs); got != tt.want {
+				t.Errorf("playlist = %q, want %q", got, tt.want)
+			}
+			if err == nil && ids(restored) != tt.want {
+				t.Errorf("RestoreTo returned %q, want %q", ids(restored), tt.want)
+			}
+
+			history, _ := GetHistory(id)
+			events := len(steps)
+			if tt.err == nil && tt.event != len(steps) {
+				events++
+			}
+			if len(history.Events) != events {
+				t.Errorf("%d events, want %d", len(history.Events), events)
+			}
+		})
+	}
+}
+
+func TestRestoreToThenUndo(t *testing.T) {
+	id := newPlaylist(t)
+	for _, step := range []string{"+a", "+b", "+c", "-a"} {
+		apply(t, id, step)
+	}
+	if _, err := RestoreTo(id, "bob", 1); err != nil {
+		t.Fatal(err)
+	}
+	after, err := Undo(id, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(after); got != "b c" {
+		t.Errorf("undoing the restore gave %q, want %q", got, "b c")
+	}
+}
+
+func TestRestoreFromTrash(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   []string
+		trash   []TrashItem
+		restore string
+		want    string
+		err     error
+	}{
+		{"removed song", []string{"+a", "+b", "-a"}, nil, "a", "b a", nil},
+		{"unknown song", []string{"+a"}, nil, "x", "a", ErrSongNotFound},
+		{"added again", []string{"+a", "-a", "+a"}, nil, "a", "a", ErrSongNotFound},
+		{
+			name:    "expired",
+			steps:   []string{"+b"},
+			trash:   []TrashItem{{Song: api.Song{ID: "a"}, RemovedAt: time.Now().Add(-TrashRetention - time.Hour)}},
+			restore: "a",
+			want:    "b",
+			err:     ErrSongNotFound,
+		},
+		{
+			name:    "about to expire",
+			steps:   []string{"+b"},
+			trash:   []TrashItem{{Song: api.Song{ID: "a"}, RemovedAt: time.Now().Add(-TrashRetention + time.Hour)}},
+			restore: "a",
+			want:    "b a",
+		},
+		{
+			name:    "already in the playlist",
+			steps:   []string{"+a"},
+			trash:   []TrashItem{{Song: api.Song{ID: "a"}, RemovedAt: time.Now()}},
+			restore: "a",
+			want:    "a",
+			err:     ErrDuplicateSong,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := newPlaylist(t)
+			for _, step := range tt.steps {
+				apply(t, id, step)
+			}
+			if tt.trash != nil {
+				history, _ := loadHistory(id)
+				history.Trash = append(history.Trash, tt.trash...)
+				if err := saveHistory(id, history); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if _, err := RestoreFromTrash(id, "bob", tt.restore); err != tt.err {
+				t.Fatalf("RestoreFromTrash err = %v, want %v", err, tt.err)
+			}
+			songs, _ := Load(id)
+			if got := ids(songs); got != tt.want {
+				t.Errorf("playlist = %q, want %q", got, tt.want)
+			}
+			history, _ := GetHistory(id)
+			for _, item := range history.Trash {
+				if item.Song.ID == tt.restore && tt.err == nil {
+					t.Errorf("%s is still in the trash", tt.restore)
+				}
+				if item.RemovedAt.Before(time.Now().Add(-TrashRetention)) {
+					t.Errorf("expired item %s is listed", item.Song.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestHistoryCap(t *testing.T) {
+	id := newPlaylist(t)
+	extra := 5
+	var all []string
+	for i := 0; i < MaxHistoryEvents+extra; i++ {
+		songID := fmt.Sprintf("s%d", i)
+		all = append(all, songID)
+		apply(t, id, "+"+songID)
+	}
+
+	history, _ := GetHistory(id)
+	if len(history.Events) != MaxHistoryEvents {
+		t.Fatalf("%d events kept, want %d", len(history.Events), MaxHistoryEvents)
+	}
+	if first := history.Events[0].ID; first != extra+1 {
+		t.Errorf("oldest event kept = %d, want %d", first, extra+1)
+	}
+
+	if _, err := RestoreTo(id, "bob", 1); err != ErrEventNotFound {
+		t.Errorf("restoring a trimmed event: err = %v, want ErrEventNotFound", err)
+	}
+	restored, err := RestoreTo(id, "bob", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ids(restored), strings.Join(all[:extra], " "); got != want {
+		t.Errorf("state before the oldest kept change = %q, want %q", got, want)
+	}
+
+	history, _ = GetHistory(id)
+	if len(history.Events) != MaxHistoryEvents {
+		t.Errorf("%d events after the restore, want %d", len(history.Events), MaxHistoryEvents)
+	}
+}
+
+func TestLoadHistoryConvertsBefore(t *testing.T) {
+	id := newPlaylist(t)
+	path, _ := historyPath(id)
+	if err := os.MkdirAll(Dir+"/history", 0755); err != nil {
+		t.Fatal(err)
+	}
+	legacy := `{"events":[{"id":1,"type":"add","before":[{"id":"a"},{"id":"b"}],"added":[{"id":"c"}]}],"trash":[]}`
+	if err := os.WriteFile(path, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := loadHistory(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := history.Events[0]
+	if !reflect.DeepEqual(event.Order, []string{"a", "b"}) || event.Before != nil {
+		t.Errorf("event = %+v, want Order a b and no Before", event)
+	}
+}
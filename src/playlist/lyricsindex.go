@@ -0,0 +1,157 @@
+package playlist
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"harmonify/src/api"
+)
+
+var (
+	// indexMu makes indexing jobs run one at a time, so a large import does
+	// not fire a burst of requests at the lyrics provider.
+	indexMu sync.Mutex
+
+	pendingMu sync.Mutex
+	pending   = make(map[string]bool)
+)
+
+// IndexLyrics starts indexing the lyrics of the songs in playlist id that
+// still need it, and returns how many are still waiting.
+func IndexLyrics(id string, songs []api.Song) int {
+	var missing []api.Song
+	for _, song := range songs {
+		if needsIndexing(song) {
+			missing = append(missing, song)
+		}
+	}
+	if queued := queueLyrics(id, missing); len(queued) > 0 {
+		go indexLyrics(id, queued)
+	}
+
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+	waiting := 0
+	for _, song := range songs {
+		if pending[id+"/"+song.ID] {
+			waiting++
+		}
+	}
+	return waiting
+}
+
+// needsIndexing reports whether indexing has something left to do for
+// song: look its lyrics up, or tag it with the mood and language of lyrics
+// already in the index. Songs without lyrics are done until the index
+// retries them, and so are songs whose language was unclear.
+func needsIndexing(song api.Song) bool {
+	if api.NeedsLyricsIndex(song) {
+		return true
+	}
+	if hasLyrics, _ := api.HasIndexedLyrics(song.ID); !hasLyrics {
+		return false
+	}
+	if song.Sentiment == "" {
+		return true
+	}
+	language, _ := api.IndexedLanguage(song.ID)
+	return language != "" && song.Language == ""
+}
+
+// queueLyrics marks songs of playlist id as waiting to be indexed and
+// returns the ones that were not already waiting.
+func queueLyrics(id string, songs []api.Song) []api.Song {
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+
+	var queued []api.Song
+	for _, song := range songs {
+		if !pending[id+"/"+song.ID] {
+			pending[id+"/"+song.ID] = true
+			queued = append(queued, song)
+		}
+	}
+	return queued
+}
+
+// dequeueLyrics drops the songs removed from playlist id from its queue.
+// Their lyrics stay in the index, where lyrics search, similar songs and
+// songbooks of other playlists still use them.
+func dequeueLyrics(id string, songs []api.Song) {
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+
+	for _, song := range songs {
+		delete(pending, id+"/"+song.ID)
+	}
+}
+
+// indexLyrics fetches the lyrics of songs added to playlist id into the
+// lyrics index, and tags the songs with the mood and language of their
+// lyrics. The songs must have been queued with queueLyrics.
+func indexLyrics(id string, added []api.Song) {
+	if len(added) == 0 {
+		return
+	}
+
+	indexMu.Lock()
+	defer indexMu.Unlock()
+	defer func() {
+		if err := api.FlushLyricsIndex(); err != nil {
+			log.Printf("Error saving the lyrics index: %v", err)
+		}
+	}()
+
+	for _, song := range added {
+		if api.NeedsLyricsIndex(song) {
+			var lyrics *api.ParsedLyrics
+			if result, err := api.FetchLyrics(context.Background(), song.Title, song.Artist); err == nil {
+				lyrics = &result.Parsed
+			}
+			if err := api.IndexLyrics(song, lyrics); err != nil {
+				log.Printf("Error indexing lyrics for %s: %v", song.ID, err)
+			}
+		}
+		if mood, ok := api.IndexedMood(song.ID); ok && song.Sentiment == "" {
+			err := tagSong(id, song.ID, func(s *api.Song) {
+				s.Sentiment, s.Moods = mood.Sentiment, mood.Tags
+			})
+			if err != nil {
+				log.Printf("Error tagging the mood of %s: %v", song.ID, err)
+			}
+		}
+		if language, _ := api.IndexedLanguage(song.ID); language != "" && song.Language == "" {
+			err := tagSong(id, song.ID, func(s *api.Song) {
+				s.Language = language
+			})
+			if err != nil {
+				log.Printf("Error tagging the language of %s: %v", song.ID, err)
+			}
+		}
+
+		pendingMu.Lock()
+		delete(pending, id+"/"+song.ID)
+		pendingMu.Unlock()
+	}
+}
+
+// tagSong stores what was learned from the lyrics of a song of playlist
+// id. It is not a change anyone made, so it is not recorded in the
+// playlist's history.
+func tagSong(id, songID string, tag func(*api.Song)) error {
+	fileMu.Lock()
+	defer fileMu.Unlock()
+
+	songs, err := load(id)
+	if err != nil {
+		return err
+	}
+	for i := range songs {
+		if songs[i].ID == songID {
+			tag(&songs[i])
+			return save(id, songs)
+		}
+	}
+	return nil
+}
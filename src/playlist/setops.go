@@ -0,0 +1,125 @@
+package playlist
+
+import (
+	"errors"
+	"strings"
+
+	"harmonify/src/api"
+)
+
+type SetOp string
+
+const (
+	OpUnion     SetOp = "union"
+	OpIntersect SetOp = "intersect"
+	OpDiff      SetOp = "diff"
+)
+
+var ErrInvalidOp = errors.New("unknown playlist operation")
+
+// Combine applies op to two song lists. Songs are considered the same when
+// they share an ID or have the same normalized title and artist. The
+// result keeps the order of a, followed by the new songs from b for a
+// union, and never contains the same song twice.
+func Combine(op SetOp, a, b []api.Song) ([]api.Song, error) {
+	inB := newSongSet(b)
+
+	var result []api.Song
+	seen := newSongSet(nil)
+	keep := func(song api.Song) {
+		if !seen.has(song) {
+			seen.add(song)
+			result = append(result, song)
+		}
+	}
+
+	switch op {
+	case OpUnion:
+		for _, song := range a {
+			keep(song)
+		}
+		for _, song := range b {
+			keep(song)
+		}
+	case OpIntersect:
+		for _, song := range a {
+			if inB.has(song) {
+				keep(song)
+			}
+		}
+	case OpDiff:
+		for _, song := range a {
+			if !inB.has(song) {
+				keep(song)
+			}
+		}
+	default:
+		return nil, ErrInvalidOp
+	}
+	return result, nil
+}
+
+// Dedupe collapses songs that are the same recording saved under different
+// IDs, keeping the first copy of each.
+func Dedupe(songs []api.Song) (kept, removed []api.Song) {
+	seen := newSongSet(nil)
+	for _, song := range songs {
+		if seen.has(song) {
+			removed = append(removed, song)
+			continue
+		}
+		seen.add(song)
+		kept = append(kept, song)
+	}
+	return kept, removed
+}
+
+// DedupePlaylist removes duplicate recordings from a stored playlist and
+// returns the songs that were dropped. Removed songs land in the trash.
+func DedupePlaylist(id, actor string) ([]api.Song, error) {
+	var removed []api.Song
+	_, err := Update(id, actor, EventRemove, func(songs []api.Song) ([]api.Song, error) {
+		var kept []api.Song
+		kept, removed = Dedupe(songs)
+		return kept, nil
+	})
+	return removed, err
+}
+
+type songSet struct {
+	ids  map[string]bool
+	keys map[string]bool
+}
+
+func newSongSet(songs []api.Song) songSet {
+	set := songSet{ids: make(map[string]bool), keys: make(map[string]bool)}
+	for _, song := range songs {
+		set.add(song)
+	}
+	return set
+}
+
+func (s songSet) add(song api.Song) {
+	s.ids[strings.ToLower(song.ID)] = true
+	if key := songKey(song); key != "" {
+		s.keys[key] = true
+	}
+}
+
+func (s songSet) has(song api.Song) bool {
+	if s.ids[strings.ToLower(song.ID)] {
+		return true
+	}
+	key := songKey(song)
+	return key != "" && s.keys[key]
+}
+
+// songKey is the normalized title and artist used to spot the same song
+// saved under a different ID.
+func songKey(song api.Song) string {
+	title, artist := api.NormalizeText(song.Title), api.NormalizeText(song.Artist)
+	if title == "" {
+		return ""
+	}
+	return title + "|" + artist
+}
@@ -0,0 +1,168 @@
+package playlist
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type Share struct {
+	Token      string    `json:"token"`
+	PlaylistID string    `json:"playlist_id"`
+	Owner      string    `json:"owner"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at,omitempty"`
+	Revoked    bool      `json:"revoked"`
+}
+
+var (
+	SharesFile = "data/shares.json"
+
+	ErrShareNotFound = errors.New("share link not found")
+	ErrShareRevoked  = errors.New("share link has been revoked")
+	ErrShareExpired  = errors.New("share link has expired")
+
+	sharesMu sync.Mutex
+)
+
+func (s Share) Expired() bool {
+	return !s.ExpiresAt.IsZero() && time.Now().After(s.ExpiresAt)
+}
+
+func (s Share) Active() bool {
+	return !s.Revoked && !s.Expired()
+}
+
+func CreateShare(owner, playlistID string, ttl time.Duration) (Share, error) {
+	if _, err := Path(playlistID); err != nil {
+		return Share{}, err
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return Share{}, err
+	}
+
+	share := Share{
+		Token:      token,
+		PlaylistID: playlistID,
+		Owner:      owner,
+		CreatedAt:  time.Now(),
+	}
+	if ttl > 0 {
+		share.ExpiresAt = share.CreatedAt.Add(ttl)
+	}
+
+	sharesMu.Lock()
+	defer sharesMu.Unlock()
+
+	shares, err := loadShares()
+	if err != nil {
+		return Share{}, err
+	}
+	shares = append(shares, share)
+	return share, saveShares(shares)
+}
+
+func GetShare(token string) (Share, error) {
+	sharesMu.Lock()
+	defer sharesMu.Unlock()
+
+	shares, err := loadShares()
+	if err != nil {
+		return Share{}, err
+	}
+
+	for _, share := range shares {
+		if share.Token != token {
+			continue
+		}
+		if share.Revoked {
+			return share, ErrShareRevoked
+		}
+		if share.Expired() {
+			return share, ErrShareExpired
+		}
+		return share, nil
+	}
+	return Share{}, ErrShareNotFound
+}
+
+func RevokeShare(owner, token string) error {
+	sharesMu.Lock()
+	defer sharesMu.Unlock()
+
+	shares, err := loadShares()
+	if err != nil {
+		return err
+	}
+
+	for i := range shares {
+		if shares[i].Token == token && shares[i].Owner == owner {
+			shares[i].Revoked = true
+			return saveShares(shares)
+		}
+	}
+	return ErrShareNotFound
+}
+
+func ActiveShares(owner, playlistID string) ([]Share, error) {
+	sharesMu.Lock()
+	defer sharesMu.Unlock()
+
+	shares, err := loadShares()
+	if err != nil {
+		return nil, err
+	}
+
+	var active []Share
+	for _, share := range shares {
+		if share.Owner == owner && share.PlaylistID == playlistID && share.Active() {
+			active = append(active, share)
+		}
+	}
+	return active, nil
+}
+
+func loadShares() ([]Share, error) {
+	if _, err := os.Stat(SharesFile); os.IsNotExist(err) {
+		return []Share{}, nil
+	}
+
+	data, err := ioutil.ReadFile(SharesFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var shares []Share
+	if err := json.Unmarshal(data, &shares); err != nil {
+		return nil, err
+	}
+	return shares, nil
+}
+
+func saveShares(shares []Share) error {
+	if err := os.MkdirAll(filepath.Dir(SharesFile), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(shares, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(SharesFile, data, 0644)
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
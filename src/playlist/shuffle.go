@@ -0,0 +1,95 @@
+package playlist
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+
+	"harmonify/src/api"
+)
+
+const (
+	shuffleWindow = 4
+	artistPenalty = 100.0
+	albumPenalty  = 20.0
+	decadePenalty = 5.0
+	backlogBonus  = 8.0
+)
+
+// SmartShuffle returns songs in a random order that keeps tracks by the
+// same artist, from the same album or from the same decade apart. The same
+// seed always yields the same order for the same songs, whatever order
+// they come in.
+func SmartShuffle(songs []api.Song, seed int64) []api.Song {
+	remaining := append([]api.Song(nil), songs...)
+	sort.SliceStable(remaining, func(i, j int) bool {
+		return remaining[i].ID < remaining[j].ID
+	})
+
+	rng := rand.New(rand.NewSource(seed))
+	rng.Shuffle(len(remaining), func(i, j int) {
+		remaining[i], remaining[j] = remaining[j], remaining[i]
+	})
+
+	left := make(map[string]int)
+	for _, song := range remaining {
+		left[artistKey(song)]++
+	}
+
+	order := make([]api.Song, 0, len(songs))
+	for len(remaining) > 0 {
+		best, bestScore := 0, 0.0
+		for i, candidate := range remaining {
+			score := spreadPenalty(order, candidate) - backlogBonus*float64(left[artistKey(candidate)])/float64(len(remaining))
+			if i == 0 || score < bestScore {
+				best, bestScore = i, score
+			}
+		}
+
+		picked := remaining[best]
+		order = append(order, picked)
+		left[artistKey(picked)]--
+		remaining = append(remaining[:best], remaining[best+1:]...)
+	}
+	return order
+}
+
+// spreadPenalty grows the closer candidate would land to a recent song by
+// the same artist, album or decade.
+func spreadPenalty(order []api.Song, candidate api.Song) float64 {
+	penalty := 0.0
+	for d := 1; d <= shuffleWindow && d <= len(order); d++ {
+		prev := order[len(order)-d]
+		weight := 1.0 / float64(d)
+
+		if artistKey(prev) == artistKey(candidate) {
+			penalty += artistPenalty * weight
+		}
+		if album := albumKey(candidate); album != "" && album == albumKey(prev) {
+			penalty += albumPenalty * weight
+		}
+		if decade := decadeOf(candidate); decade != 0 && decade == decadeOf(prev) {
+			penalty += decadePenalty * weight
+		}
+	}
+	return penalty
+}
+
+func albumKey(song api.Song) string {
+	return strings.ToLower(strings.TrimSpace(song.Album))
+}
+
+func decadeOf(song api.Song) int {
+	if song.ReleaseDate.IsZero() {
+		return 0
+	}
+	return song.ReleaseDate.Year() / 10 * 10
+}
+
+// Shuffle saves a smart-shuffled order for the playlist as a reorder event,
+// so it can be undone from the history.
+func Shuffle(id, actor string, seed int64) ([]api.Song, error) {
+	return Update(id, actor, EventReorder, func(songs []api.Song) ([]api.Song, error) {
+		return SmartShuffle(songs, seed), nil
+	})
+}
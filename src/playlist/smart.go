@@ -0,0 +1,274 @@
+package playlist
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"harmonify/src/api"
+)
+
+// SmartRules describes which songs belong to a smart playlist. Every rule
+// that is set must match.
+type SmartRules struct {
+	Filters     api.SearchFilters `json:"filters"`
+	Text        string            `json:"text,omitempty"`
+	Artists     []string          `json:"artists,omitempty"`
+	ArtistsFrom string            `json:"artists_from,omitempty"`
+}
+
+type SmartPlaylist struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Owner     string     `json:"owner"`
+	Rules     SmartRules `json:"rules"`
+	Source    string     `json:"source,omitempty"`
+	LiveQuery string     `json:"live_query,omitempty"`
+	Limit     int        `json:"limit,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+var (
+	ErrSmartNotFound = errors.New("smart playlist not found")
+
+	smartMu sync.Mutex
+)
+
+func CreateSmart(sp SmartPlaylist) (SmartPlaylist, error) {
+	token, err := newToken()
+	if err != nil {
+		return SmartPlaylist{}, err
+	}
+
+	sp.ID = "smart_" + token[:12]
+	sp.Name = strings.TrimSpace(sp.Name)
+	if sp.Name == "" {
+		sp.Name = "Smart playlist"
+	}
+	sp.CreatedAt = time.Now()
+
+	smartMu.Lock()
+	defer smartMu.Unlock()
+
+	all, err := loadSmart(sp.Owner)
+	if err != nil {
+		return SmartPlaylist{}, err
+	}
+	return sp, saveSmart(sp.Owner, append(all, sp))
+}
+
+func SmartPlaylistsFor(owner string) ([]SmartPlaylist, error) {
+	smartMu.Lock()
+	defer smartMu.Unlock()
+	return loadSmart(owner)
+}
+
+func GetSmart(owner, id string) (SmartPlaylist, error) {
+	all, err := SmartPlaylistsFor(owner)
+	if err != nil {
+		return SmartPlaylist{}, err
+	}
+	for _, sp := range all {
+		if sp.ID == id {
+			return sp, nil
+		}
+	}
+	return SmartPlaylist{}, ErrSmartNotFound
+}
+
+func DeleteSmart(owner, id string) error {
+	smartMu.Lock()
+	defer smartMu.Unlock()
+
+	all, err := loadSmart(owner)
+	if err != nil {
+		return err
+	}
+	for i, sp := range all {
+		if sp.ID == id {
+			return saveSmart(owner, append(all[:i], all[i+1:]...))
+		}
+	}
+	return ErrSmartNotFound
+}
+
+// Evaluate runs the rules against the owner's saved songs (or the single
+// source playlist, if one is set) plus the optional live Spotify query.
+func (sp SmartPlaylist) Evaluate() ([]api.Song, error) {
+	candidates, err := sp.candidates()
+	if err != nil {
+		return nil, err
+	}
+
+	artists := make(map[string]bool)
+	for _, artist := range sp.Rules.Artists {
+		if artist = strings.ToLower(strings.TrimSpace(artist)); artist != "" {
+			artists[artist] = true
+		}
+	}
+	if sp.Rules.ArtistsFrom != "" {
+		if !RoleFor(sp.Rules.ArtistsFrom, sp.Owner).CanView() {
+			return nil, ErrForbidden
+		}
+		songs, err := Load(sp.Rules.ArtistsFrom)
+		if err != nil {
+			return nil, err
+		}
+		for _, song := range songs {
+			artists[strings.ToLower(strings.TrimSpace(song.Artist))] = true
+		}
+	}
+
+	var matched []api.Song
+	for _, song := range candidates {
+		if len(artists) > 0 && !artists[strings.ToLower(strings.TrimSpace(song.Artist))] {
+			continue
+		}
+		matched = append(matched, song)
+	}
+
+	matched = api.FilterSongs(matched, sp.Rules.Text, sp.Rules.Filters)
+	if sp.Limit > 0 && len(matched) > sp.Limit {
+		matched = matched[:sp.Limit]
+	}
+	return matched, nil
+}
+
+func (sp SmartPlaylist) candidates() ([]api.Song, error) {
+	var sources []string
+	if sp.Source != "" {
+		if !RoleFor(sp.Source, sp.Owner).CanView() {
+			return nil, ErrForbidden
+		}
+		sources = []string{sp.Source}
+	} else {
+		accessible, err := Accessible(sp.Owner)
+		if err != nil {
+			return nil, err
+		}
+		for _, meta := range accessible {
+			sources = append(sources, meta.ID)
+		}
+	}
+
+	seen := make(map[string]bool)
+	var songs []api.Song
+	for _, id := range sources {
+		loaded, err := Load(id)
+		if err != nil {
+			return nil, err
+		}
+		for _, song := range loaded {
+			if !seen[song.ID] {
+				seen[song.ID] = true
+				songs = append(songs, song)
+			}
+		}
+	}
+
+	if sp.LiveQuery != "" {
+		live, _, err := api.SearchSpotifySongs(sp.LiveQuery, 1, api.SearchFilters{})
+		if err != nil {
+			return nil, fmt.Errorf("live query failed: %v", err)
+		}
+		for _, song := range live {
+			if !seen[song.ID] {
+				seen[song.ID] = true
+				songs = append(songs, song)
+			}
+		}
+	}
+	return songs, nil
+}
+
+// Summary describes the rules in a short human readable sentence.
+func (r SmartRules) Summary() string {
+	var parts []string
+	if r.Text != "" {
+		parts = append(parts, fmt.Sprintf("matching %q", r.Text))
+	}
+	if r.Filters.StartDate != "" {
+		parts = append(parts, "released on or after "+r.Filters.StartDate)
+	}
+	if r.Filters.EndDate != "" {
+		parts = append(parts, "released on or before "+r.Filters.EndDate)
+	}
+	if r.Filters.MinDuration > 0 {
+		parts = append(parts, fmt.Sprintf("at least %d:%02d long", api.DurationMinutes(r.Filters.MinDuration), api.DurationSeconds(r.Filters.MinDuration)))
+	}
+	if r.Filters.MaxDuration > 0 {
+		parts = append(parts, fmt.Sprintf("at most %d:%02d long", api.DurationMinutes(r.Filters.MaxDuration), api.DurationSeconds(r.Filters.MaxDuration)))
+	}
+	switch r.Filters.LyricsFilter {
+	case "with_lyrics":
+		parts = append(parts, "with lyrics")
+	case "without_lyrics":
+		parts = append(parts, "without lyrics")
+	}
+	if r.Filters.Mood != "" {
+		parts = append(parts, "with a "+r.Filters.Mood+" mood")
+	}
+	if r.Filters.Language != "" {
+		parts = append(parts, "sung in "+api.LanguageName(r.Filters.Language))
+	}
+	if len(r.Artists) > 0 {
+		parts = append(parts, "by "+strings.Join(r.Artists, ", "))
+	}
+
+	if len(parts) == 0 {
+		return "All songs"
+	}
+	return "Songs " + strings.Join(parts, ", ")
+}
+
+func smartPath(owner string) (string, error) {
+	if !validID.MatchString(owner) {
+		return "", fmt.Errorf("invalid owner: %q", owner)
+	}
+	return filepath.Join(Dir, "smart", owner+".json"), nil
+}
+
+func loadSmart(owner string) ([]SmartPlaylist, error) {
+	path, err := smartPath(owner)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return []SmartPlaylist{}, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var all []SmartPlaylist
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+func saveSmart(owner string, all []SmartPlaylist) error {
+	path, err := smartPath(owner)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,36 @@
+package playlist
+
+import (
+	"testing"
+
+	"harmonify/src/api"
+)
+
+func TestSmartRulesSummary(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules SmartRules
+		want  string
+	}{
+		{"no rules", SmartRules{}, "All songs"},
+		{"text", SmartRules{Text: "love"}, `Songs matching "love"`},
+		{"mood", SmartRules{Filters: api.SearchFilters{Mood: "happy"}}, "Songs with a happy mood"},
+		{"language", SmartRules{Filters: api.SearchFilters{Language: "es"}}, "Songs sung in Spanish"},
+		{
+			name: "everything",
+			rules: SmartRules{
+				Text:    "night",
+				Filters: api.SearchFilters{MaxDuration: 240000, LyricsFilter: "with_lyrics", Mood: "sad", Language: "en"},
+				Artists: []string{"Adele"},
+			},
+			want: `Songs matching "night", at most 4:00 long, with lyrics, with a sad mood, sung in English, by Adele`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rules.Summary(); got != tt.want {
+				t.Errorf("Summary() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package playlist
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"harmonify/src/api"
+)
+
+var (
+	Dir       = "data/playlists"
+	DefaultID = "default"
+
+	ErrDuplicateSong = errors.New("song already in playlist")
+	ErrSongNotFound  = errors.New("song not found in playlist")
+
+	validID = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	fileMu  sync.Mutex
+)
+
+func UserPlaylistID(username string) string {
+	return fmt.Sprintf("%s_playlist", username)
+}
+
+func Path(id string) (string, error) {
+	if !validID.MatchString(id) {
+		return "", fmt.Errorf("invalid playlist id: %q", id)
+	}
+	return filepath.Join(Dir, id+".json"), nil
+}
+
+func Load(id string) ([]api.Song, error) {
+	fileMu.Lock()
+	defer fileMu.Unlock()
+	return load(id)
+}
+
+func Save(id string, songs []api.Song) error {
+	fileMu.Lock()
+	defer fileMu.Unlock()
+	return save(id, songs)
+}
+
+// Update reloads the playlist from disk and applies fn while holding the
+// store lock, so concurrent editors never overwrite each other's changes.
+// Every successful update is recorded in the playlist's history.
+func Update(id, actor string, kind EventType, fn func([]api.Song) ([]api.Song, error)) ([]api.Song, error) {
+	fileMu.Lock()
+	defer fileMu.Unlock()
+
+	before, err := load(id)
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := fn(append([]api.Song(nil), before...))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := save(id, after); err != nil {
+		return nil, err
+	}
+	if err := record(id, actor, kind, before, after); err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+func AddSong(id, actor string, song api.Song) ([]api.Song, error) {
+	return Update(id, actor, EventAdd, func(songs []api.Song) ([]api.Song, error) {
+		for _, existing := range songs {
+			if strings.EqualFold(existing.ID, song.ID) {
+				return nil, ErrDuplicateSong
+			}
+		}
+		return append(songs, song), nil
+	})
+}
+
+func RemoveSong(id, actor, songID string) ([]api.Song, error) {
+	return Update(id, actor, EventRemove, func(songs []api.Song) ([]api.Song, error) {
+		for i, song := range songs {
+			if song.ID == songID {
+				return append(songs[:i], songs[i+1:]...), nil
+			}
+		}
+		return nil, ErrSongNotFound
+	})
+}
+
+func load(id string) ([]api.Song, error) {
+	path, err := Path(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return []api.Song{}, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var songs []api.Song
+	if err := json.Unmarshal(data, &songs); err != nil {
+		return nil, err
+	}
+	return songs, nil
+}
+
+func save(id string, songs []api.Song) error {
+	path, err := Path(id)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(Dir, 0755); err != nil {
+		return err
+	}
+
+	if songs == nil {
+		songs = []api.Song{}
+	}
+	data, err := json.MarshalIndent(songs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
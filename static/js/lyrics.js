@@ -4,7 +4,7 @@ document.addEventListener('DOMContentLoaded', function () {
     const backButton = document.querySelector('.btn-back');
 
     copyButton?.addEventListener('click', async () => {
-        const lyrics = document.querySelector('.lyrics-pre').textContent;
+        const lyrics = (document.querySelector('.lyrics-raw') || document.querySelector('.lyrics-pre')).textContent;
         try {
             await navigator.clipboard.writeText(lyrics);
             showToast('Lyrics copied to clipboard!', 'success');
@@ -27,48 +27,177 @@ document.addEventListener('DOMContentLoaded', function () {
                 url.searchParams.append('page', currentQuery.get('page'));
             }
 
-            fetch(url, {
-                redirect: 'follow'
-            })
-            .then(response => {
-                if (response.redirected) {
-                    // Extract action from redirect URL
-                    const redirectUrl = new URL(response.url);
-                    const action = redirectUrl.searchParams.get('action');
-                    
-                    if (action === 'added') {
-                        showToast('Added to playlist!', 'success');
-                        e.target.textContent = 'Remove from Playlist';
-                        e.target.classList.remove('btn-add-playlist');
-                        e.target.classList.add('btn-remove-playlist');
-                        const songId = url.searchParams.get('id');
-                        e.target.href = `/remove-from-playlist?id=${songId}`;
-                    } else if (action === 'removed') {
-                        showToast('Removed from playlist!', 'success');
-                        e.target.textContent = 'Add to Playlist';
-                        e.target.classList.remove('btn-remove-playlist');
-                        e.target.classList.add('btn-add-playlist');
-                        const songId = url.searchParams.get('id');
-                        const title = url.searchParams.get('title');
-                        const artist = url.searchParams.get('artist');
-                        e.target.href = `/add-to-playlist?id=${songId}&title=${title}&artist=${artist}`;
-                    } else if (action === 'already_exists') {
-                        showToast('Song is already in playlist!', 'info');
-                    } else if (action === 'failed') {
-                        showToast('Failed to update playlist', 'error');
-                    }
-                    if (!window.location.pathname.includes('/lyrics')) {
-                        window.location.href = response.url;
+            updatePlaylist(e.target, url);
+        });
+    });
+
+    function updatePlaylist(button, url) {
+        fetch(url, {
+            redirect: 'follow'
+        })
+        .then(response => {
+            if (response.redirected) {
+                // Extract action from redirect URL
+                const redirectUrl = new URL(response.url);
+                const action = redirectUrl.searchParams.get('action');
+
+                if (action === 'version_exists') {
+                    const addUrl = confirmVersionAdd(redirectUrl.searchParams);
+                    if (addUrl) {
+                        updatePlaylist(button, new URL(addUrl, window.location.origin));
                     }
+                    return;
                 }
-            })
-            .catch(error => {
-                console.error('Failed to update playlist:', error);
-                showToast('Failed to update playlist', 'error');
-            });
+
+                if (action === 'added') {
+                    showToast('Added to playlist!', 'success');
+                    button.textContent = 'Remove from Playlist';
+                    button.classList.remove('btn-add-playlist');
+                    button.classList.add('btn-remove-playlist');
+                    const songId = url.searchParams.get('id');
+                    button.href = `/remove-from-playlist?id=${songId}`;
+                } else if (action === 'removed') {
+                    showToast('Removed from playlist!', 'success');
+                    button.textContent = 'Add to Playlist';
+                    button.classList.remove('btn-remove-playlist');
+                    button.classList.add('btn-add-playlist');
+                    const songId = url.searchParams.get('id');
+                    const title = url.searchParams.get('title');
+                    const artist = url.searchParams.get('artist');
+                    button.href = `/add-to-playlist?id=${songId}&title=${title}&artist=${artist}`;
+                } else if (action === 'already_exists') {
+                    showToast('Song is already in playlist!', 'info');
+                } else if (action === 'failed') {
+                    showToast('Failed to update playlist', 'error');
+                }
+                if (!window.location.pathname.includes('/lyrics')) {
+                    window.location.href = response.url;
+                }
+            }
+        })
+        .catch(error => {
+            console.error('Failed to update playlist:', error);
+            showToast('Failed to update playlist', 'error');
+        });
+    }
+
+    const pageAction = new URLSearchParams(window.location.search).get('action');
+    if (pageAction === 'lrc_uploaded') {
+        showToast('Synced lyrics uploaded!', 'success');
+    } else if (pageAction === 'lrc_invalid') {
+        showToast('That file has no timed LRC lines', 'error');
+    } else if (pageAction === 'sync_saved') {
+        showToast('Sync saved for everyone!', 'success');
+    } else if (pageAction === 'offset_saved') {
+        showToast('Offset saved!', 'success');
+    } else if (pageAction === 'chords_saved') {
+        showToast('Chord sheet saved for everyone!', 'success');
+    } else if (pageAction === 'chords_invalid') {
+        showToast('That sheet has no [chords] in it', 'error');
+    } else if (pageAction === 'chords_removed') {
+        showToast('Chord sheet removed', 'success');
+    } else if (pageAction === 'failed') {
+        showToast('Something went wrong', 'error');
+    }
+
+    const synced = document.querySelector('.synced-lyrics');
+    const player = document.getElementById('preview-player');
+    if (synced && player) {
+        const lines = Array.from(synced.querySelectorAll('li[data-time]'));
+        let current = -1;
+
+        player.addEventListener('timeupdate', function () {
+            const position = player.currentTime * 1000 + parseInt(synced.dataset.offset || '0', 10);
+            let index = -1;
+            for (let i = 0; i < lines.length && parseInt(lines[i].dataset.time, 10) <= position; i++) {
+                index = i;
+            }
+            if (index === current) {
+                return;
+            }
+            lines[current]?.classList.remove('active');
+            current = index;
+            if (current >= 0) {
+                lines[current].classList.add('active');
+                lines[current].scrollIntoView({ block: 'nearest', behavior: 'smooth' });
+            }
+        });
+    }
+
+    document.querySelectorAll('.btn-offset').forEach(button => {
+        button.addEventListener('click', function () {
+            const input = document.getElementById('sync-offset');
+            input.value = (parseInt(input.value || '0', 10) + parseInt(button.dataset.step, 10)).toString();
+            if (synced) {
+                synced.dataset.offset = input.value;
+            }
         });
     });
 
+    const editorToggle = document.getElementById('sync-editor-toggle');
+    const editor = document.querySelector('.sync-editor');
+    editorToggle?.addEventListener('click', function () {
+        editor.hidden = !editor.hidden;
+    });
+
+    const editorLines = Array.from(document.querySelectorAll('.sync-editor-lines li'));
+    const saveForm = document.getElementById('sync-save-form');
+    if (editor && player && editorLines.length > 0) {
+        const stamps = [];
+
+        function formatStamp(ms) {
+            const cs = Math.floor(ms / 10);
+            const pad = n => n.toString().padStart(2, '0');
+            return pad(Math.floor(cs / 6000)) + ':' + pad(Math.floor(cs / 100) % 60) + '.' + pad(cs % 100);
+        }
+
+        function render() {
+            editorLines.forEach((line, i) => {
+                line.querySelector('.sync-time').textContent = i < stamps.length ? '[' + formatStamp(stamps[i]) + '] ' : '';
+                line.classList.toggle('active', i === stamps.length);
+                line.classList.toggle('stamped', i < stamps.length);
+            });
+            editorLines[stamps.length]?.scrollIntoView({ block: 'nearest', behavior: 'smooth' });
+        }
+
+        function stamp() {
+            if (stamps.length < editorLines.length) {
+                stamps.push(Math.round(player.currentTime * 1000));
+                render();
+            }
+        }
+
+        document.getElementById('sync-stamp').addEventListener('click', stamp);
+        document.getElementById('sync-back').addEventListener('click', function () {
+            stamps.pop();
+            render();
+        });
+        document.getElementById('sync-reset').addEventListener('click', function () {
+            stamps.length = 0;
+            player.currentTime = 0;
+            render();
+        });
+        document.addEventListener('keydown', function (e) {
+            if (!editor.hidden && e.code === 'Space' && e.target.tagName !== 'INPUT') {
+                e.preventDefault();
+                stamp();
+            }
+        });
+
+        saveForm.addEventListener('submit', function (e) {
+            if (stamps.length === 0) {
+                e.preventDefault();
+                showToast('Stamp at least one line first', 'error');
+                return;
+            }
+            document.getElementById('sync-lrc').value = stamps
+                .map((ms, i) => '[' + formatStamp(ms) + ']' + editorLines[i].querySelector('.sync-text').textContent)
+                .join('\n');
+        });
+
+        render();
+    }
+
     function showToast(message, status) {
         const toast = document.getElementById('toast');
         if (toast) {
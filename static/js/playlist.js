@@ -8,6 +8,46 @@ document.addEventListener('DOMContentLoaded', function () {
         showToast('Song already in playlist!', 'info');
     } else if (action === 'not_found') {
         showToast('Song not found in playlist!', 'error');
+    } else if (action === 'shared') {
+        showToast('Share link created!', 'success');
+    } else if (action === 'revoked') {
+        showToast('Share link revoked!', 'success');
+    } else if (action === 'added') {
+        showToast('Added to playlist!', 'success');
+    } else if (action === 'version_exists') {
+        const addUrl = confirmVersionAdd(urlParams);
+        if (addUrl) {
+            window.location.href = addUrl;
+        }
+    } else if (action === 'created') {
+        showToast('Playlist created!', 'success');
+    } else if (action === 'invited') {
+        showToast('Invitation sent!', 'success');
+    } else if (action === 'invite_failed') {
+        showToast('Could not invite that user', 'error');
+    } else if (action === 'joined') {
+        showToast('You joined the playlist!', 'success');
+    } else if (action === 'declined') {
+        showToast('Invitation declined', 'info');
+    } else if (action === 'left') {
+        showToast('You left the playlist', 'info');
+    } else if (action === 'member_removed') {
+        showToast('Member removed', 'success');
+    } else if (action === 'undone') {
+        showToast('Last change undone!', 'success');
+    } else if (action === 'nothing_to_undo') {
+        showToast('Nothing to undo', 'info');
+    } else if (action === 'restored') {
+        showToast('Playlist restored!', 'success');
+    } else if (action === 'shuffled') {
+        showToast('Playlist shuffled with seed ' + urlParams.get('seed') + '!', 'success');
+    } else if (action === 'deduped') {
+        const count = urlParams.get('count');
+        showToast(count === '0' ? 'No duplicates found' : 'Removed ' + count + ' duplicate(s)!', count === '0' ? 'info' : 'success');
+    } else if (action === 'deleted') {
+        showToast('Playlist deleted', 'success');
+    } else if (action === 'failed') {
+        showToast('Failed to update playlist', 'error');
     }
 
     function showToast(message, status) {
@@ -25,6 +65,18 @@ document.addEventListener('DOMContentLoaded', function () {
         card.classList.toggle('flipped');
     }
 
+    const filterForm = document.getElementById('filterForm');
+    filterForm?.addEventListener('submit', function () {
+        updateDuration('min');
+        updateDuration('max');
+    });
+
+    function updateDuration(type) {
+        const minutes = parseInt(document.getElementById(`${type}DurationMinutes`).value) || 0;
+        const seconds = parseInt(document.getElementById(`${type}DurationSeconds`).value) || 0;
+        document.getElementById(`${type}Duration`).value = (minutes * 60 + seconds) * 1000;
+    }
+
     const songCards = document.querySelectorAll('.song-card');
     songCards.forEach(card => {
         let clickCount = 0;
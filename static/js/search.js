@@ -4,6 +4,11 @@ document.addEventListener('DOMContentLoaded', function () {
 
     if (action === 'added') {
         showToast('Added to playlist!', 'success');
+    } else if (action === 'version_exists') {
+        const addUrl = confirmVersionAdd(urlParams);
+        if (addUrl) {
+            window.location.href = addUrl;
+        }
     } else if (action === 'already_exists') {
         showToast('Song already in playlist!', 'info');
     } else if (action === 'failed') {
@@ -0,0 +1,12 @@
+// Adding a song when another version of it is already in the playlist
+// comes back with action=version_exists instead of adding it. This asks
+// the user and returns the URL that adds the song anyway, or null.
+function confirmVersionAdd(params) {
+    const versionOf = params.get('version_of');
+    const addUrl = params.get('add');
+    if (!versionOf || !addUrl || !addUrl.startsWith('/add-to-playlist?')) {
+        return null;
+    }
+    const message = '"' + versionOf + '" is another version of this song and is already in the playlist. Add this one too?';
+    return window.confirm(message) ? addUrl : null;
+}